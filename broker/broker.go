@@ -0,0 +1,22 @@
+package broker
+
+// Handler 处理从topic中消费到的消息
+type Handler func(payload []byte)
+
+// Broker 网关与逻辑服务之间的消息队列，同一个topic的每条消息只会被一个订阅者消费
+type Broker interface {
+	Publish(topic string, payload []byte) error
+	Subscribe(topic string, handler Handler) error
+	Unsubscribe(topic string) error
+	Close() error
+}
+
+// ServiceTopic 发往逻辑服务的消息，由该服务的任意一个节点消费
+func ServiceTopic(serviceName string) string {
+	return "service." + serviceName
+}
+
+// GatewayTopic 发往某个网关(或者服务间调用的发起方)的消息
+func GatewayTopic(serviceID string) string {
+	return "gateway." + serviceID
+}
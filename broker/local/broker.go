@@ -0,0 +1,237 @@
+package local
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cirno-im/broker"
+	"cirno-im/logger"
+)
+
+const (
+	// pollInterval 订阅者检查新消息的间隔
+	pollInterval = time.Millisecond * 20
+	// batchSize 每次最多消费的消息数
+	batchSize = 100
+	// truncateSize 所有消息都被消费之后，超过这个大小的文件会被清空
+	truncateSize = 4 * 1024 * 1024
+	// staleLock 锁文件超过这个时间没有释放时认为持有者已经退出
+	staleLock = time.Second * 10
+)
+
+var log = logger.WithField("module", "broker.local")
+
+// Broker 基于本地文件的消息队列，用于开发和测试，同一台机器上的多个进程可以共享一个目录
+//
+// 每个topic对应一个追加写入的.log文件和一个记录消费位置的.offset文件，读写时通过.lock文件互斥
+type Broker struct {
+	dir string
+
+	sync.Mutex
+	locks  map[string]*sync.Mutex
+	subs   map[string]chan struct{}
+	closed bool
+}
+
+func New(dir string) (*Broker, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Broker{
+		dir:   dir,
+		locks: make(map[string]*sync.Mutex),
+		subs:  make(map[string]chan struct{}),
+	}, nil
+}
+
+func (b *Broker) path(topic, ext string) string {
+	return filepath.Join(b.dir, topic+ext)
+}
+
+// lock 先获取进程内的锁，再通过创建锁文件与其它进程互斥
+func (b *Broker) lock(topic string) (func(), error) {
+	b.Lock()
+	mu, ok := b.locks[topic]
+	if !ok {
+		mu = new(sync.Mutex)
+		b.locks[topic] = mu
+	}
+	b.Unlock()
+	mu.Lock()
+
+	name := b.path(topic, ".lock")
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_ = f.Close()
+			return func() {
+				_ = os.Remove(name)
+				mu.Unlock()
+			}, nil
+		}
+		if !os.IsExist(err) {
+			mu.Unlock()
+			return nil, err
+		}
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > staleLock {
+			log.Warnf("remove stale lock %s", name)
+			_ = os.Remove(name)
+			continue
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (b *Broker) Publish(topic string, payload []byte) error {
+	unlock, err := b.lock(topic)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(b.path(topic, ".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[4:], payload)
+	_, err = f.Write(buf)
+	return err
+}
+
+func (b *Broker) Subscribe(topic string, handler broker.Handler) error {
+	b.Lock()
+	defer b.Unlock()
+	if b.closed {
+		return errors.New("broker closed")
+	}
+	if _, ok := b.subs[topic]; ok {
+		return fmt.Errorf("topic %s has been subscribed", topic)
+	}
+	quit := make(chan struct{})
+	b.subs[topic] = quit
+	go b.poll(topic, handler, quit)
+	return nil
+}
+
+func (b *Broker) Unsubscribe(topic string) error {
+	b.Lock()
+	defer b.Unlock()
+	if quit, ok := b.subs[topic]; ok {
+		close(quit)
+		delete(b.subs, topic)
+	}
+	return nil
+}
+
+func (b *Broker) Close() error {
+	b.Lock()
+	defer b.Unlock()
+	b.closed = true
+	for topic, quit := range b.subs {
+		close(quit)
+		delete(b.subs, topic)
+	}
+	return nil
+}
+
+func (b *Broker) poll(topic string, handler broker.Handler, quit chan struct{}) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+		for {
+			payloads, err := b.consume(topic)
+			if err != nil {
+				log.Warnf("consume %s failed: %v", topic, err)
+				break
+			}
+			for _, payload := range payloads {
+				handler(payload)
+			}
+			if len(payloads) < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// consume 从上次消费的位置读取一批消息，并提交新的消费位置
+func (b *Broker) consume(topic string) ([][]byte, error) {
+	unlock, err := b.lock(topic)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(b.path(topic, ".log"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset, err := b.readOffset(topic)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= info.Size() {
+		if info.Size() > truncateSize {
+			if err = f.Truncate(0); err != nil {
+				return nil, err
+			}
+			return nil, b.writeOffset(topic, 0)
+		}
+		return nil, nil
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	rd := bufio.NewReader(f)
+	payloads := make([][]byte, 0)
+	head := make([]byte, 4)
+	for len(payloads) < batchSize && offset < info.Size() {
+		if _, err = io.ReadFull(rd, head); err != nil {
+			return nil, err
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(head))
+		if _, err = io.ReadFull(rd, payload); err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, payload)
+		offset += int64(4 + len(payload))
+	}
+	return payloads, b.writeOffset(topic, offset)
+}
+
+func (b *Broker) readOffset(topic string) (int64, error) {
+	bts, err := os.ReadFile(b.path(topic, ".offset"))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(bts)), 10, 64)
+}
+
+func (b *Broker) writeOffset(topic string, offset int64) error {
+	return os.WriteFile(b.path(topic, ".offset"), []byte(strconv.FormatInt(offset, 10)), 0644)
+}
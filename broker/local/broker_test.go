@@ -0,0 +1,49 @@
+package local
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker(t *testing.T) {
+	dir := t.TempDir()
+	producer, err := New(dir)
+	assert.Nil(t, err)
+
+	// 两个消费者模拟两个进程，每条消息只被消费一次
+	var (
+		lock sync.Mutex
+		got  = make(map[string]int)
+	)
+	for i := 0; i < 2; i++ {
+		consumer, err := New(dir)
+		assert.Nil(t, err)
+		defer consumer.Close()
+		err = consumer.Subscribe("service.chat", func(payload []byte) {
+			lock.Lock()
+			defer lock.Unlock()
+			got[string(payload)]++
+		})
+		assert.Nil(t, err)
+	}
+
+	for i := 0; i < 200; i++ {
+		assert.Nil(t, producer.Publish("service.chat", []byte(fmt.Sprintf("msg%d", i))))
+	}
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(got) == 200
+	}, time.Second*5, time.Millisecond*20)
+
+	time.Sleep(pollInterval * 3)
+	lock.Lock()
+	defer lock.Unlock()
+	for msg, n := range got {
+		assert.Equal(t, 1, n, msg)
+	}
+}
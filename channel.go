@@ -19,9 +19,17 @@ type ChannelImpl struct {
 	readWait  time.Duration
 	gPool     *ants.Pool
 	state     int32 // 0 init 1 start 2 closed
+	// done 写循环退出并关闭连接之后关闭
+	done chan struct{}
+	// closed Close之后关闭，writeChan不会被关闭，避免与并发的Push冲突
+	closed chan struct{}
 }
 
 func NewChannel(id string, meta Meta, conn Conn, gpool *ants.Pool) Channel {
+	return newChannel(id, meta, conn, gpool)
+}
+
+func newChannel(id string, meta Meta, conn Conn, gpool *ants.Pool) *ChannelImpl {
 	ch := &ChannelImpl{
 		id:        id,
 		Conn:      conn,
@@ -31,8 +39,11 @@ func NewChannel(id string, meta Meta, conn Conn, gpool *ants.Pool) Channel {
 		readWait:  constants.DefaultReadWait,
 		gPool:     gpool,
 		state:     0,
+		done:      make(chan struct{}),
+		closed:    make(chan struct{}),
 	}
 	go func() {
+		defer close(ch.done)
 		err := ch.writeLoop()
 		if err != nil {
 			logger.WithFields(logger.Fields{
@@ -40,6 +51,8 @@ func NewChannel(id string, meta Meta, conn Conn, gpool *ants.Pool) Channel {
 				"id":     id,
 			}).Info(err)
 		}
+		// 写循环退出之后关闭连接，读循环随之退出
+		_ = ch.Conn.Close()
 	}()
 	return ch
 }
@@ -53,25 +66,46 @@ func (ch *ChannelImpl) writeLoop() error {
 	defer func() {
 		log.Debugf("channel %s writeloop exited", ch.id)
 	}()
-	for payload := range ch.writeChan {
-		err := ch.WriteFrame(OpBinary, payload)
-		if err != nil {
-			return err
-		}
-		chanlen := len(ch.writeChan)
-		for i := 0; i < chanlen; i++ {
-			payload = <-ch.writeChan
-			err := ch.WriteFrame(OpBinary, payload)
+	// 以登录时的帧格式回复
+	op := OpBinary
+	if ch.meta[constants.MetaKeyFrame] == constants.FrameText {
+		op = OpText
+	}
+	for {
+		select {
+		case payload := <-ch.writeChan:
+			_ = ch.SetWriteDeadline(time.Now().Add(ch.writeWait))
+			err := ch.WriteFrame(op, payload)
 			if err != nil {
 				return err
 			}
-		}
-		err = ch.Flush()
-		if err != nil {
-			return err
+			chanlen := len(ch.writeChan)
+			for i := 0; i < chanlen; i++ {
+				payload = <-ch.writeChan
+				err := ch.WriteFrame(op, payload)
+				if err != nil {
+					return err
+				}
+			}
+			err = ch.Flush()
+			if err != nil {
+				return err
+			}
+		case <-ch.closed:
+			// 发完关闭之前已经推送的消息再退出
+			_ = ch.SetWriteDeadline(time.Now().Add(ch.writeWait))
+			for {
+				select {
+				case payload := <-ch.writeChan:
+					if err := ch.WriteFrame(op, payload); err != nil {
+						return err
+					}
+				default:
+					return ch.Flush()
+				}
+			}
 		}
 	}
-	return nil
 }
 
 // ID id simpling server
@@ -82,9 +116,13 @@ func (ch *ChannelImpl) Push(payload []byte) error {
 	if atomic.LoadInt32(&ch.state) != 1 {
 		return fmt.Errorf("channel %s has closed", ch.id)
 	}
-	// 异步写
-	ch.writeChan <- payload
-	return nil
+	// 异步写，队列满时等待，连接关闭后返回错误
+	select {
+	case ch.writeChan <- payload:
+		return nil
+	case <-ch.closed:
+		return fmt.Errorf("channel %s has closed", ch.id)
+	}
 }
 
 // Close 关闭连接
@@ -92,10 +130,20 @@ func (ch *ChannelImpl) Close() error {
 	if !atomic.CompareAndSwapInt32(&ch.state, 1, 2) {
 		return fmt.Errorf("channel has started")
 	}
-	close(ch.writeChan)
+	close(ch.closed)
 	return nil
 }
 
+// wait 等待写循环退出，之后连接的读写缓冲区不会再被使用
+func (ch *ChannelImpl) wait() {
+	<-ch.done
+}
+
+// QueueDepth 等待写入连接的消息数
+func (ch *ChannelImpl) QueueDepth() int {
+	return len(ch.writeChan)
+}
+
 // SetWriteWait 设置写超时
 func (ch *ChannelImpl) SetWriteWait(writeWait time.Duration) {
 	if writeWait == 0 {
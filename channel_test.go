@@ -0,0 +1,52 @@
+package cim
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pipeConn 把每一帧的payload直接写入net.Pipe
+type pipeConn struct {
+	net.Conn
+}
+
+func (c *pipeConn) ReadFrame() (Frame, error) {
+	_, err := c.Read(make([]byte, 1))
+	return nil, err
+}
+
+func (c *pipeConn) WriteFrame(_ OpCode, payload []byte) error {
+	_, err := c.Write(payload)
+	return err
+}
+
+func (c *pipeConn) Flush() error { return nil }
+
+func TestChannelCloseWhilePush(t *testing.T) {
+	local, remote := net.Pipe()
+	go func() { _, _ = io.Copy(io.Discard, remote) }()
+	ch := newChannel("ch1", nil, &pipeConn{Conn: local}, nil)
+	go func() { _ = ch.ReadLoop(nil) }()
+	assert.Eventually(t, func() bool { return ch.Push([]byte("hello")) == nil }, time.Second, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = ch.Push([]byte("hello"))
+			}
+		}()
+	}
+	// 并发的Push不会因为Close而panic，Close之后的Push返回错误
+	assert.Nil(t, ch.Close())
+	wg.Wait()
+	assert.NotNil(t, ch.Push([]byte("hello")))
+	ch.wait()
+}
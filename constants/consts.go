@@ -7,6 +7,28 @@ const (
 	DefaultWriteWait = time.Second * 10
 	DefaultLoginWait = time.Second * 10
 	DefaultHearBeat  = time.Minute
+	// DefaultRequestWait 服务间调用等待响应的默认超时
+	DefaultRequestWait = time.Second * 5
+	// 依赖服务断线重连的退避时间
+	DefaultReconnectBackoff    = time.Millisecond * 500
+	DefaultReconnectMaxBackoff = time.Second * 30
+	// DefaultRetryWindow 没有可用的逻辑服务时消息在重试缓冲区中最多等待的时间
+	DefaultRetryWindow = time.Second * 10
+	// DefaultWarmUp 新发现的逻辑服务的预热时长
+	DefaultWarmUp = time.Second * 10
+	// DefaultKeyRefresh 网关重新加载token密钥的间隔
+	DefaultKeyRefresh = time.Minute
+	// DefaultKeyGrace 密钥被轮换后仍然有效的时长
+	DefaultKeyGrace = time.Hour
+	// DefaultRoyalRefresh 逻辑服务缓存royal节点列表的时长
+	DefaultRoyalRefresh = time.Second * 5
+)
+
+const (
+	// DefaultUnhealthyAttempts 连续重连失败多少次后把依赖服务标记为不健康
+	DefaultUnhealthyAttempts = 5
+	// DefaultPushHistory 网关为每个channel保留的最近推送数，用于客户端请求重发
+	DefaultPushHistory = 128
 )
 
 const (
@@ -15,7 +37,20 @@ const (
 	DefaultConnectionPool  = 5000
 )
 
+// DefaultBrokerDir 本地消息队列的默认目录
+const DefaultBrokerDir = "./data/broker"
+
 const (
 	MetaKeyApp     = "app"
 	MetaKeyAccount = "account"
+	// MetaKeyFrame 连接使用的帧格式，登录时确定
+	MetaKeyFrame = "frame"
+	// MetaKeyTokenID 登录token的jti，撤销token时用来找到对应的连接
+	MetaKeyTokenID = "jti"
+)
+
+// 帧格式，text为浏览器客户端使用的JSON格式
+const (
+	FrameBinary = "binary"
+	FrameText   = "text"
 )
@@ -0,0 +1,205 @@
+package container
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	cim "cirno-im"
+	"cirno-im/constants"
+	"cirno-im/logger"
+)
+
+// AdminTokenHeader 调用管理接口时携带token的请求头，也可以使用Authorization: Bearer <token>
+const AdminTokenHeader = "X-Admin-Token"
+
+// SetAdminToken 设置管理接口的token，为空时不开放管理接口
+func (c *Container) SetAdminToken(token string) {
+	c.adminToken.Store(token)
+}
+
+// ChannelInfo 管理接口返回的连接信息
+type ChannelInfo struct {
+	ID         string   `json:"id"`
+	Meta       cim.Meta `json:"meta"`
+	RemoteAddr string   `json:"remote_addr"`
+	QueueDepth int      `json:"queue_depth"`
+}
+
+// ClientInfo 管理接口返回的依赖服务节点信息
+type ClientInfo struct {
+	ServiceName string            `json:"service_name"`
+	ServiceID   string            `json:"service_id"`
+	Address     string            `json:"address"`
+	Meta        map[string]string `json:"meta"`
+	Breaker     string            `json:"breaker"`
+	Failures    int               `json:"failures"`
+	Latency     string            `json:"latency"`
+	Inflight    int               `json:"inflight"`
+	Unhealthy   bool              `json:"unhealthy"`
+	WarmUp      float64           `json:"warm_up"`
+	Connections int               `json:"connections"`
+}
+
+func (c *Container) handleAdmin(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/channels", c.admin(c.listChannels))
+	mux.HandleFunc("GET /admin/channels/{id}", c.admin(c.getChannel))
+	mux.HandleFunc("DELETE /admin/channels/{id}", c.admin(c.kickChannel))
+	mux.HandleFunc("GET /admin/clients", c.admin(c.listClients))
+	mux.HandleFunc("GET /admin/loglevel", c.admin(getLogLevel))
+	mux.HandleFunc("PUT /admin/loglevel", c.admin(setLogLevel))
+
+	mux.HandleFunc("/debug/pprof/", c.admin(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", c.admin(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", c.admin(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", c.admin(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", c.admin(pprof.Trace))
+}
+
+// admin 校验管理接口的token
+func (c *Container) admin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected, _ := c.adminToken.Load().(string)
+		if expected == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token := r.Header.Get(AdminTokenHeader)
+		if token == "" {
+			token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, val any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(val)
+}
+
+func channelInfo(ch cim.Channel) ChannelInfo {
+	info := ChannelInfo{
+		ID:   ch.ID(),
+		Meta: ch.GetMetadata(),
+	}
+	if addr := ch.RemoteAddr(); addr != nil {
+		info.RemoteAddr = addr.String()
+	}
+	if q, ok := ch.(interface{ QueueDepth() int }); ok {
+		info.QueueDepth = q.QueueDepth()
+	}
+	return info
+}
+
+// listChannels 列出当前节点上的连接，可以通过account和app过滤
+func (c *Container) listChannels(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get(constants.MetaKeyAccount)
+	app := r.URL.Query().Get(constants.MetaKeyApp)
+	list := make([]ChannelInfo, 0)
+	for _, ch := range c.channels.All() {
+		meta := ch.GetMetadata()
+		if account != "" && meta[constants.MetaKeyAccount] != account {
+			continue
+		}
+		if app != "" && meta[constants.MetaKeyApp] != app {
+			continue
+		}
+		list = append(list, channelInfo(ch))
+	}
+	writeJSON(w, list)
+}
+
+func (c *Container) getChannel(w http.ResponseWriter, r *http.Request) {
+	ch, ok := c.channels.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, channelInfo(ch))
+}
+
+// kickChannel 断开连接
+func (c *Container) kickChannel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ch, ok := c.channels.Get(id)
+	if !ok {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
+	log.WithField("func", "kickChannel").Infof("kick channel %s by admin", id)
+	if err := ch.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var breakerStates = map[int]string{
+	breakerClosed:   "closed",
+	breakerOpen:     "open",
+	breakerHalfOpen: "half_open",
+}
+
+// listClients 列出依赖服务的节点及其状态
+func (c *Container) listClients(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	list := make([]ClientInfo, 0)
+	for name, clients := range c.srvClients {
+		for _, srv := range c.latest(clients.Services()) {
+			id := srv.ServiceID()
+			info := ClientInfo{
+				ServiceName: name,
+				ServiceID:   id,
+				Meta:        srv.GetMetadata(),
+				Inflight:    c.Inflight(id),
+				WarmUp:      c.factor(id, now),
+				Connections: 1,
+			}
+			c.RLock()
+			if reg, ok := c.registered[name][id]; ok {
+				info.Address = reg.DialURL()
+			}
+			c.RUnlock()
+			b := c.breaker(name, id)
+			b.Lock()
+			info.Breaker = breakerStates[b.state]
+			info.Failures = b.failures
+			info.Latency = b.latency.String()
+			b.Unlock()
+			_, info.Unhealthy = c.unhealthy.Load(id)
+			if val, ok := c.pools.Load(id); ok {
+				info.Connections = val.(*pool).size()
+			}
+			list = append(list, info)
+		}
+	}
+	writeJSON(w, list)
+}
+
+func getLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"level": logger.GetLevel()})
+}
+
+// setLogLevel 修改日志级别，请求体为{"level":"debug"}
+func setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Level string `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := logger.SetLevel(req.Level); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Infof("log level changed to %s by admin", req.Level)
+	writeJSON(w, map[string]string{"level": logger.GetLevel()})
+}
@@ -0,0 +1,195 @@
+package container
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	cim "cirno-im"
+	"cirno-im/constants"
+)
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+const (
+	// BreakerFailures 连续失败多少次后断开
+	BreakerFailures = 5
+	// BreakerCooldown 断开之后多久进入半开状态，允许试探请求
+	BreakerCooldown = time.Second * 10
+	// OutlierFactor 平均响应时间超过所有节点中位数的多少倍时剔除
+	OutlierFactor = 3
+	// OutlierMinLatency 平均响应时间低于这个值时不会被剔除
+	OutlierMinLatency = time.Millisecond * 200
+	// OutlierMaxEjection 最多剔除的节点比例
+	OutlierMaxEjection = 0.5
+)
+
+// breaker 依赖服务单个节点的熔断器
+type breaker struct {
+	sync.Mutex
+	name     string
+	id       string
+	state    int
+	failures int
+	openedAt time.Time
+	// trialAt 半开状态下试探请求的发出时间
+	trialAt time.Time
+	// latency 响应时间的指数加权平均
+	latency time.Duration
+}
+
+func newBreaker(name, id string) *breaker {
+	breakerState.WithLabelValues(name, id).Set(breakerClosed)
+	return &breaker{name: name, id: id}
+}
+
+func (b *breaker) setState(state int) {
+	b.state = state
+	breakerState.WithLabelValues(b.name, b.id).Set(float64(state))
+}
+
+// available 当前是否可以接收消息
+func (b *breaker) available(now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+	switch b.state {
+	case breakerOpen:
+		return now.Sub(b.openedAt) >= BreakerCooldown
+	case breakerHalfOpen:
+		// 试探请求超时未响应时允许再次试探
+		return now.Sub(b.trialAt) >= constants.DefaultRequestWait
+	}
+	return true
+}
+
+// acquire 节点被选中，如果处于冷却结束或者半开状态，这次请求作为试探请求
+func (b *breaker) acquire(now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+	if b.state == breakerClosed {
+		return
+	}
+	if b.state == breakerOpen {
+		b.setState(breakerHalfOpen)
+	}
+	b.trialAt = now
+}
+
+func (b *breaker) success(latency time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+	if b.latency == 0 {
+		b.latency = latency
+	} else {
+		b.latency = (b.latency*4 + latency) / 5
+	}
+	b.failures = 0
+	if b.state != breakerClosed {
+		log.WithField("service_id", b.id).Infoln("circuit breaker closed")
+		b.setState(breakerClosed)
+	}
+}
+
+func (b *breaker) failure(reason string, now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+	dependencyFailures.WithLabelValues(b.name, b.id, reason).Inc()
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= BreakerFailures) {
+		b.open(now)
+	}
+}
+
+func (b *breaker) open(now time.Time) {
+	log.WithField("service_id", b.id).Warnf("circuit breaker opened after %d failures", b.failures)
+	b.openedAt = now
+	b.setState(breakerOpen)
+}
+
+// eject 因响应过慢被剔除
+func (b *breaker) eject(now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+	if b.state != breakerClosed {
+		return
+	}
+	log.WithField("service_id", b.id).Warnf("ejected as an outlier, latency %v", b.latency)
+	outlierEjections.WithLabelValues(b.name, b.id).Inc()
+	// 清零平均响应时间，恢复之后重新统计
+	b.latency = 0
+	b.open(now)
+}
+
+func (b *breaker) getLatency() time.Duration {
+	b.Lock()
+	defer b.Unlock()
+	return b.latency
+}
+
+func (c *Container) breaker(name, id string) *breaker {
+	if val, ok := c.breakers.Load(id); ok {
+		return val.(*breaker)
+	}
+	val, _ := c.breakers.LoadOrStore(id, newBreaker(name, id))
+	return val.(*breaker)
+}
+
+func (c *Container) reportFailure(name, id, reason string) {
+	c.breaker(name, id).failure(reason, time.Now())
+}
+
+func (c *Container) reportSuccess(name, id string, latency time.Duration) {
+	dependencyLatency.WithLabelValues(name).Observe(latency.Seconds())
+	c.breaker(name, id).success(latency)
+}
+
+// healthy 过滤掉熔断中的节点，并剔除响应时间明显高于其它节点的节点
+func (c *Container) healthy(name string, srvs []cim.Service) []cim.Service {
+	now := time.Now()
+	latencies := make([]time.Duration, 0, len(srvs))
+	for _, srv := range srvs {
+		if l := c.breaker(name, srv.ServiceID()).getLatency(); l > 0 {
+			latencies = append(latencies, l)
+		}
+	}
+	c.ejectOutliers(name, srvs, latencies, now)
+
+	res := make([]cim.Service, 0, len(srvs))
+	for _, srv := range srvs {
+		if c.breaker(name, srv.ServiceID()).available(now) {
+			res = append(res, srv)
+		}
+	}
+	return res
+}
+
+func (c *Container) ejectOutliers(name string, srvs []cim.Service, latencies []time.Duration, now time.Time) {
+	if len(latencies) < 3 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	median := latencies[len(latencies)/2]
+
+	ejected := 0
+	for _, srv := range srvs {
+		if !c.breaker(name, srv.ServiceID()).available(now) {
+			ejected++
+		}
+	}
+	max := int(float64(len(srvs)) * OutlierMaxEjection)
+	for _, srv := range srvs {
+		if ejected >= max {
+			return
+		}
+		b := c.breaker(name, srv.ServiceID())
+		l := b.getLatency()
+		if l > OutlierMinLatency && l > median*OutlierFactor {
+			b.eject(now)
+			ejected++
+		}
+	}
+}
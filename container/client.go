@@ -3,6 +3,7 @@ package container
 import (
 	"cirno-im"
 	"cirno-im/logger"
+	"sort"
 	"sync"
 )
 
@@ -49,7 +50,7 @@ func (c *ClientsImpl) Get(id string) (client cim.Client, ok bool) {
 	return val.(cim.Client), true
 }
 
-// 返回服务列表，传一对
+// 返回服务列表，传一对，按ServiceID排序保证相同的服务集合得到相同的顺序
 func (c *ClientsImpl) Services(kvs ...string) []cim.Service {
 	kvLen := len(kvs)
 	if kvLen != 0 && kvLen != 2 {
@@ -64,5 +65,8 @@ func (c *ClientsImpl) Services(kvs ...string) []cim.Service {
 		arr = append(arr, service)
 		return true
 	})
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].ServiceID() < arr[j].ServiceID()
+	})
 	return arr
 }
@@ -0,0 +1,35 @@
+package container
+
+import (
+	"cirno-im/constants"
+	"cirno-im/wire/pkt"
+)
+
+// SetChannelCodec 设置推送给channel的消息使用的body变换方式，为空时删除
+func (c *Container) SetChannelCodec(channelID string, codec *pkt.Codec) {
+	if codec == nil {
+		c.codecs.Delete(channelID)
+		return
+	}
+	c.codecs.Store(channelID, codec)
+}
+
+// ChannelCodec 返回channel登录时协商出的变换方式
+func (c *Container) ChannelCodec(channelID string) (*pkt.Codec, bool) {
+	codec, ok := c.codecs.Load(channelID)
+	if !ok {
+		return nil, false
+	}
+	return codec.(*pkt.Codec), true
+}
+
+// channelPayload 按照channel登录时的帧格式和协商结果序列化推送的消息
+func (c *Container) channelPayload(channelID string, packet *pkt.LogicPkt) ([]byte, error) {
+	if ch, ok := c.channels.Get(channelID); ok && ch.GetMetadata()[constants.MetaKeyFrame] == constants.FrameText {
+		return pkt.MarshalText(packet)
+	}
+	if codec, ok := c.ChannelCodec(channelID); ok {
+		return pkt.MarshalWith(packet, codec), nil
+	}
+	return pkt.Marshal(packet), nil
+}
@@ -0,0 +1,124 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"cirno-im/constants"
+	"cirno-im/wire/command"
+	"cirno-im/wire/pkt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CommandInfo 管理接口返回的命令信息，Fields为请求中的字段及其类型
+type CommandInfo struct {
+	Command  string            `json:"command"`
+	Request  string            `json:"request,omitempty"`
+	Response string            `json:"response,omitempty"`
+	Push     string            `json:"push,omitempty"`
+	Required []string          `json:"required,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// ConsoleRequest 调试控制台的请求，以ChannelID对应的会话身份把Body发给逻辑服务
+type ConsoleRequest struct {
+	Command   string          `json:"command"`
+	ChannelID string          `json:"channel_id"`
+	Dest      string          `json:"dest"`
+	Service   string          `json:"service"` // 为空时使用命令的第一段
+	Body      json.RawMessage `json:"body"`
+}
+
+// ConsoleResponse 调试控制台的响应，Body按照登记的响应类型解析
+type ConsoleResponse struct {
+	Status   string `json:"status"`
+	Sequence uint32 `json:"sequence"`
+	Body     any    `json:"body,omitempty"`
+}
+
+func (c *Container) handleConsole(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/commands", c.admin(listCommands))
+	mux.HandleFunc("POST /admin/console", c.admin(c.console))
+}
+
+func listCommands(w http.ResponseWriter, r *http.Request) {
+	list := make([]CommandInfo, 0)
+	for _, s := range command.All() {
+		info := CommandInfo{
+			Command:  s.Command,
+			Request:  command.TypeName(s.Request),
+			Response: command.TypeName(s.Response),
+			Push:     command.TypeName(s.Push),
+			Required: s.Required,
+		}
+		if s.Request != nil {
+			info.Fields = make(map[string]string)
+			fields := s.Request.ProtoReflect().Descriptor().Fields()
+			for i := 0; i < fields.Len(); i++ {
+				info.Fields[string(fields.Get(i).Name())] = fieldType(fields.Get(i))
+			}
+		}
+		list = append(list, info)
+	}
+	writeJSON(w, list)
+}
+
+func fieldType(fd protoreflect.FieldDescriptor) string {
+	kind := fd.Kind().String()
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		kind = string(fd.Message().FullName())
+	case protoreflect.EnumKind:
+		kind = string(fd.Enum().FullName())
+	}
+	if fd.IsList() {
+		return "repeated " + kind
+	}
+	return kind
+}
+
+// console 校验JSON格式的body并转发给逻辑服务，等待响应
+func (c *Container) console(w http.ResponseWriter, r *http.Request) {
+	var req ConsoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	schema, ok := command.Lookup(req.Command)
+	if !ok {
+		http.Error(w, "command is not registered", http.StatusBadRequest)
+		return
+	}
+	if len(req.Body) == 0 {
+		req.Body = json.RawMessage("{}")
+	}
+	body, err := schema.Validate(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	packet := pkt.New(req.Command, pkt.WithChannel(req.ChannelID), pkt.WithDest(req.Dest))
+	packet.WriteBody(body)
+	service := req.Service
+	if service == "" {
+		service = packet.ServiceName()
+	}
+	log.WithField("func", "console").Infof("send %s to %s as %s by admin", req.Command, service, req.ChannelID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), constants.DefaultRequestWait)
+	defer cancel()
+	resp, err := c.Request(ctx, service, packet)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	result := ConsoleResponse{
+		Status:   resp.Status.String(),
+		Sequence: resp.Sequence,
+	}
+	if result.Body, err = command.Decode(resp); err != nil {
+		result.Body = resp.StringBody()
+	}
+	writeJSON(w, result)
+}
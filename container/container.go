@@ -3,23 +3,22 @@ package container
 import (
 	"bytes"
 	cim "cirno-im"
+	"cirno-im/broker"
 	"cirno-im/constants"
 	"cirno-im/logger"
 	"cirno-im/naming"
 	"cirno-im/tcp"
 	"cirno-im/wire"
+	"cirno-im/wire/command"
 	"cirno-im/wire/pkt"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"net/http"
-	"os"
-	"os/signal"
 	"strings"
 	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 )
 
@@ -30,6 +29,7 @@ const (
 	stateClosed
 )
 
+// 服务注册时可以在metadata中声明KeyServiceState为StateYoung，就绪之后更新为StateAdult
 const (
 	StateYoung = "young"
 	StateAdult = "adult"
@@ -39,6 +39,12 @@ const (
 	KeyServiceState = "service_state"
 )
 
+// callKey 服务间调用的标识，客户端的Sequence可能与wire.Seq重复，需要同时比较ChannelID
+type callKey struct {
+	channelID string
+	sequence  uint32
+}
+
 type Container struct {
 	sync.RWMutex
 	Naming naming.Naming
@@ -49,27 +55,70 @@ type Container struct {
 	selector   Selector
 	dialer     cim.Dialer
 	deps       map[string]struct{}
-	monitor    sync.Once
+	monitor    *http.Server
+	done       chan struct{}
+	// warmup 新发现的服务的预热时长
+	warmup time.Duration
+	// states 依赖服务节点的状态，见serviceState
+	states sync.Map
+	// calls 等待响应的服务间调用，key为请求的ChannelID和Sequence
+	calls sync.Map
+	// inflights 每个服务未响应的消息，供LeastInflightSelector使用
+	inflights sync.Map
+	// breakers 依赖服务每个节点的熔断器
+	breakers sync.Map
+	// pools 到依赖服务每个节点的连接池，poolSize为1时不使用
+	pools    sync.Map
+	poolSize int
+	// channels 按照服务ID索引对端连接池中的channel
+	channels *poolChannels
+	// broker 不为空时通过消息队列代替TCP连接与其它服务通信
+	broker   broker.Broker
+	listener cim.MessageListener
+	// adminToken 管理接口的token
+	adminToken atomic.Value
+	// draining 为1时表示正在下线
+	draining uint32
+	checks   healthChecks
+	// registered 依赖服务在注册中心的最新列表，断线重连前用来确认服务仍然存在
+	registered   map[string]map[string]cim.ServiceRegistration
+	reconnecting sync.Map
+	unhealthy    sync.Map
+	// retries 没有可用节点时缓存待转发的消息，retrySize为0时不开启
+	retries     sync.Map
+	retrySize   int
+	retryWindow time.Duration
+	// codecs 网关上每个channel协商出的body变换方式
+	codecs sync.Map
+	// sequences 网关上每个channel的请求去重窗口和推送记录，pushHistory为每个channel保留的推送数
+	sequences   sync.Map
+	pushHistory int
 }
 
 var log = logger.WithField("module", "container")
 
-var c = &Container{
-	state:    0,
-	selector: &HashSelector{},
-	deps:     map[string]struct{}{},
-}
-
-func Default() *Container {
-	return c
+// New 创建一个容器，同一个进程中可以创建多个
+func New() *Container {
+	return &Container{
+		state:       stateUninitialized,
+		selector:    &HashSelector{},
+		deps:        map[string]struct{}{},
+		done:        make(chan struct{}),
+		warmup:      constants.DefaultWarmUp,
+		poolSize:    1,
+		pushHistory: constants.DefaultPushHistory,
+		registered:  map[string]map[string]cim.ServiceRegistration{},
+	}
 }
 
-func Init(srv cim.Server, deps ...string) error {
+func (c *Container) Init(srv cim.Server, deps ...string) error {
 	if !atomic.CompareAndSwapUint32(&c.state, stateUninitialized, stateInitalized) {
 		return errors.New("container already inited")
 	}
 
 	c.Srv = srv
+	c.channels = newPoolChannels(cim.NewChannels(100))
+	srv.SetChannelMap(c.channels)
 	for _, dep := range deps {
 		if _, ok := c.deps[dep]; ok {
 			continue
@@ -81,36 +130,45 @@ func Init(srv cim.Server, deps ...string) error {
 	return nil
 }
 
-func SetDialer(dialer cim.Dialer) {
+func (c *Container) SetDialer(dialer cim.Dialer) {
 	c.dialer = dialer
 }
 
 // EnableMonitor start
-func EnableMonitor(listen string) {
-	c.monitor.Do(func() {
-		go func() {
-			http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-				_, _ = w.Write([]byte("ok"))
-			})
-			// add prometheus metrics
-			http.Handle("/metrics", promhttp.Handler())
-			_ = http.ListenAndServe(listen, nil)
-		}()
-	})
+func (c *Container) EnableMonitor(listen string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.monitor != nil {
+		return
+	}
+	mux := http.NewServeMux()
+	c.handleHealth(mux)
+	// add prometheus metrics
+	mux.Handle("/metrics", promhttp.Handler())
+	c.handleAdmin(mux)
+	c.handleConsole(mux)
+	c.monitor = &http.Server{Addr: listen, Handler: mux}
+	go func(monitor *http.Server) {
+		_ = monitor.ListenAndServe()
+	}(c.monitor)
 }
 
-func SetSelector(selector Selector) {
+func (c *Container) SetSelector(selector Selector) {
 	c.selector = selector
 }
 
-func SetServiceNaming(name naming.Naming) {
+func (c *Container) SetServiceNaming(name naming.Naming) {
 	c.Naming = name
 }
 
-func Start() error {
+// Start 启动服务、连接依赖的服务并注册到注册中心，不会阻塞
+func (c *Container) Start(ctx context.Context) error {
 	if c.Naming == nil {
 		return errors.New("naming is nil")
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if !atomic.CompareAndSwapUint32(&c.state, stateInitalized, stateStart) {
 		return errors.New("container already started")
 	}
@@ -123,10 +181,20 @@ func Start() error {
 		}
 	}(c.Srv)
 
-	// 2. 与依赖的服务建立连接
+	// 2. 与依赖的服务建立连接，使用消息队列时订阅发给自己的消息
+	if c.broker != nil {
+		if err := c.subscribe(); err != nil {
+			return err
+		}
+	}
 	for service := range c.deps {
+		if c.broker != nil {
+			break
+		}
+		clients := NewClients(10)
+		c.srvClients[service] = clients
 		go func(service string) {
-			err := connectToService(service)
+			err := c.connectToService(service, clients)
 			if err != nil {
 				log.Errorln(err.Error())
 			}
@@ -140,22 +208,25 @@ func Start() error {
 			log.Errorln(err.Error())
 		}
 	}
-
-	// 等待中断信号
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	log.Infoln("exit signal:", <-ch)
-	return shutdown()
+	return nil
 }
 
 // Push message to server
-func Push(server string, p *pkt.LogicPkt) error {
+func (c *Container) Push(server string, p *pkt.LogicPkt) error {
 	p.AddStringMeta(wire.MetaDestServer, server)
-	return c.Srv.Push(server, pkt.Marshal(p))
+	if c.broker != nil {
+		return c.broker.Publish(broker.GatewayTopic(server), pkt.Marshal(p))
+	}
+	// 对端使用连接池时，同一个channel的消息总是通过同一个连接发送
+	key := p.ChannelID
+	if channels, ok := p.GetString(wire.MetaDestChannels); ok {
+		key = channels
+	}
+	return c.Srv.Push(c.channels.pick(server, key), pkt.Marshal(p))
 }
 
 // Forward message to services
-func Forward(serviceName string, packet *pkt.LogicPkt) error {
+func (c *Container) Forward(serviceName string, packet *pkt.LogicPkt) error {
 	if packet == nil {
 		return errors.New("packet is nil")
 	}
@@ -165,67 +236,159 @@ func Forward(serviceName string, packet *pkt.LogicPkt) error {
 	if packet.ChannelID == "" {
 		return errors.New("ChannelId is empty in packet")
 	}
-	return ForwardWithSelector(serviceName, packet, c.selector)
+	err := c.forward(serviceName, packet, c.selector)
+	if errors.Is(err, ErrNoService) && c.retrySize > 0 {
+		return c.retry(serviceName, packet, c.selector)
+	}
+	return err
 }
 
 // ForwardWithSelector forward data to the specified node of services which is chosen by selector
-func ForwardWithSelector(serviceName string, packet *pkt.LogicPkt, selector Selector) error {
-	cli, err := lookup(serviceName, &packet.Header, selector)
+func (c *Container) ForwardWithSelector(serviceName string, packet *pkt.LogicPkt, selector Selector) error {
+	return c.forward(serviceName, packet, selector)
+}
+
+func (c *Container) forward(serviceName string, packet *pkt.LogicPkt, selector Selector) error {
+	if c.broker != nil {
+		packet.AddStringMeta(wire.MetaDestServer, c.Srv.ServiceID())
+		return c.broker.Publish(broker.ServiceTopic(serviceName), pkt.Marshal(packet))
+	}
+	cli, err := c.lookup(serviceName, &packet.Header, selector)
 	if err != nil {
 		return err
 	}
 	packet.AddStringMeta(wire.MetaDestServer, c.Srv.ServiceID())
 	log.Debugf("forward message to %v with %s", cli.ServiceID(), &packet.Header)
-	return cli.Send(pkt.Marshal(packet))
+	if err = c.pick(cli, packet.ChannelID).Send(pkt.Marshal(packet)); err != nil {
+		c.reportFailure(serviceName, cli.ServiceID(), "send_error")
+		return err
+	}
+	if packet.Flag == pkt.Flag_Request {
+		c.trackInflight(cli.ServiceID(), &packet.Header)
+	}
+	return nil
+}
+
+// Request forward the packet to a node of services and wait for the response which has the same sequence
+func (c *Container) Request(ctx context.Context, serviceName string, packet *pkt.LogicPkt) (*pkt.LogicPkt, error) {
+	if packet == nil {
+		return nil, errors.New("packet is nil")
+	}
+	if packet.Command == "" {
+		return nil, errors.New("command is empty in packet")
+	}
+	packet.Sequence = wire.Seq.Next()
+	packet.Flag = pkt.Flag_Request
+	packet.AddStringMeta(wire.MetaCallFrom, c.Srv.ServiceID())
+
+	respChan := make(chan *pkt.LogicPkt, 1)
+	key := callKey{channelID: packet.ChannelID, sequence: packet.Sequence}
+	c.calls.Store(key, respChan)
+	defer c.calls.Delete(key)
+
+	if err := c.ForwardWithSelector(serviceName, packet, c.selector); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-respChan:
+		return resp, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request %s to %s: %w", packet.Command, serviceName, ctx.Err())
+	}
 }
 
-func connectToService(serviceName string) error {
-	clients := NewClients(10)
-	c.srvClients[serviceName] = clients
-	delay := time.Second * 10
-	err := c.Naming.Subscribe(serviceName, func(services []cim.ServiceRegistration) {
-		for _, service := range services {
-			if _, ok := clients.Get(service.ServiceID()); ok {
+func (c *Container) connectToService(serviceName string, clients ClientMap) error {
+	// 只发现同一个命名空间中的服务
+	ns := naming.WithNamespace(c.Naming, c.Srv.GetNamespace())
+	// 订阅只在服务有变化时回调，先连接已经存在的服务
+	services, err := ns.Find(serviceName)
+	if err != nil {
+		log.WithField("func", "connectToService").Warn(err)
+	} else {
+		c.onServiceEvents(serviceName, clients, naming.Diff(nil, services))
+	}
+	return ns.Subscribe(serviceName, func(events []naming.Event) {
+		c.onServiceEvents(serviceName, clients, events)
+	})
+}
+
+// onServiceEvents 处理依赖服务的变化：新增的建立连接，删除的关闭连接，
+// 更新的刷新metadata，地址变化时关闭旧的连接，由重连使用新的地址
+func (c *Container) onServiceEvents(serviceName string, clients ClientMap, events []naming.Event) {
+	log := log.WithField("func", "onServiceEvents")
+	for _, event := range events {
+		service := event.Service
+		id := service.ServiceID()
+		log.Infof("services %s %s: %v", serviceName, event.Type, service)
+		switch event.Type {
+		case naming.EventRemoved:
+			c.deregister(serviceName, id)
+			if cli, ok := clients.Get(id); ok {
+				cli.Close()
+			}
+		case naming.EventAdded, naming.EventUpdated:
+			old := c.register(serviceName, service)
+			c.updateState(service)
+			if cli, ok := clients.Get(id); ok {
+				if old != nil && old.DialURL() != service.DialURL() {
+					cli.Close()
+				}
 				continue
 			}
-			log.WithField("func", "connectToService").Infof("Watch a new services: %v", service)
-			service.GetMetadata()[KeyServiceState] = StateYoung
-			go func() {
-				time.Sleep(delay)
-				service.GetMetadata()[KeyServiceState] = StateAdult
-			}()
-
-			_, err := buildClient(clients, service)
-			if err != nil {
+			if _, err := c.buildClient(clients, service); err != nil {
 				logger.Warn(err)
+				go c.reconnect(clients, service)
 			}
 		}
-	})
-	if err != nil {
-		return err
 	}
-	return nil
 }
 
-func buildClient(clients ClientMap, service cim.ServiceRegistration) (cim.Client, error) {
+func (c *Container) buildClient(clients ClientMap, service cim.ServiceRegistration) (cim.Client, error) {
 	c.Lock()
 	defer c.Unlock()
-	var (
-		id       = service.ServiceID()
-		name     = service.ServiceName()
-		metadata = service.GetMetadata()
-	)
+	id := service.ServiceID()
 	//检查连接是否已经存在
 	if _, ok := clients.Get(id); ok {
 		return nil, nil
 	}
+	cli, err := c.newClient(service, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	//读取消息
+	go func(cli cim.Client) {
+		err := c.readLoop(cli)
+		if err != nil {
+			log.Errorln(err)
+		}
+		clients.Remove(id)
+		c.inflights.Delete(id)
+		if p, ok := c.pools.LoadAndDelete(id); ok {
+			p.(*pool).close()
+		}
+		cli.Close()
+		if atomic.LoadUint32(&c.state) == stateStart {
+			c.reconnect(clients, service)
+		}
+	}(cli)
+	c.buildPool(cli, service)
+	clients.Add(cli)
+	return cli, nil
+}
+
+// newClient 建立到服务的一个连接，index为连接在连接池中的序号
+func (c *Container) newClient(service cim.ServiceRegistration, index int) (cim.Client, error) {
 	//检查服务间是否使用tcp协议进行通讯
 	if service.GetProtocol() != string(wire.ProtocolTCP) {
 		return nil, errors.New("services is not a TCP protocol")
 	}
+	if err := c.CheckNamespace(service.GetNamespace()); err != nil {
+		return nil, err
+	}
 
 	//构建客户端并且进行连接
-	cli := tcp.NewClientWithProps(id, name, metadata, tcp.ClientOptions{
+	cli := tcp.NewClientWithProps(service.ServiceID(), service.ServiceName(), service.GetMetadata(), tcp.ClientOptions{
 		Heartbeat: constants.DefaultHearBeat,
 		ReadWait:  constants.DefaultReadWait,
 		WriteWait: constants.DefaultWriteWait,
@@ -233,26 +396,15 @@ func buildClient(clients ClientMap, service cim.ServiceRegistration) (cim.Client
 	if c.dialer == nil {
 		return nil, errors.New("dialer is nil")
 	}
-	cli.SetDialer(c.dialer)
+	cli.SetDialer(&indexDialer{Dialer: c.dialer, index: index, namespace: c.Srv.GetNamespace()})
 	err := cli.Connect(service.DialURL())
 	if err != nil {
 		return nil, err
 	}
-
-	//读取消息
-	go func(cli cim.Client) {
-		err := readLoop(cli)
-		if err != nil {
-			log.Errorln(err)
-		}
-		clients.Remove(id)
-		cli.Close()
-	}(cli)
-	clients.Add(cli)
 	return cli, nil
 }
 
-func readLoop(cli cim.Client) error {
+func (c *Container) readLoop(cli cim.Client) error {
 	log := logger.WithFields(logger.Fields{
 		"module": "container",
 		"func":   "readLoop",
@@ -272,75 +424,112 @@ func readLoop(cli cim.Client) error {
 			log.Errorln(err)
 			continue
 		}
-		err = pushMessage(packet)
-		if err != nil {
-			log.Errorln(err)
+		if packet.Flag == pkt.Flag_Response {
+			if latency, ok := c.doneInflight(cli.ServiceID(), &packet.Header); ok {
+				c.reportSuccess(cli.ServiceName(), cli.ServiceID(), latency)
+			}
 		}
+		c.dispatch(packet)
 	}
 }
 
-func pushMessage(packet *pkt.LogicPkt) error {
-	server, _ := packet.GetMeta(wire.MetaDestServer)
+// dispatch 处理逻辑服务发给当前节点的消息
+func (c *Container) dispatch(packet *pkt.LogicPkt) {
+	// 服务间调用的响应交给等待中的请求方，请求方已经超时的响应直接丢弃，不能推送给用户
+	if _, ok := packet.GetString(wire.MetaCallFrom); ok && packet.Flag == pkt.Flag_Response {
+		key := callKey{channelID: packet.ChannelID, sequence: packet.Sequence}
+		if respChan, ok := c.calls.LoadAndDelete(key); ok {
+			respChan.(chan *pkt.LogicPkt) <- packet
+		} else {
+			log.WithField("func", "dispatch").Warnf("drop late response of %s", &packet.Header)
+		}
+		return
+	}
+	if err := c.pushMessage(packet); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (c *Container) pushMessage(packet *pkt.LogicPkt) error {
+	server, _ := packet.GetString(wire.MetaDestServer)
 	if server != c.Srv.ServiceID() {
 		return fmt.Errorf("dest_server is not incorrect, %s != %s", server, c.Srv.ServiceID())
 	}
-	channels, ok := packet.GetMeta(wire.MetaDestChannels)
+	channels, ok := packet.GetString(wire.MetaDestChannels)
 	if !ok {
 		return fmt.Errorf("dest_channels is nil, %s", wire.MetaDestChannels)
 	}
 
-	channelIDs := strings.Split(channels.(string), ",")
+	channelIDs := strings.Split(channels, ",")
 	packet.DelMeta(wire.MetaDestServer)
 	packet.DelMeta(wire.MetaDestChannels)
-	payload := pkt.Marshal(packet)
-	log.Debugf("Push to %v %v", channelIDs, packet)
+	log.Debugf("Push to %v %v", channelIDs, command.Describe(packet))
 	for _, channelID := range channelIDs {
-		messageOutFlowBytes.WithLabelValues(packet.Command).Add(float64(len(payload)))
-		err := c.Srv.Push(channelID, payload)
-		if err != nil {
+		if err := c.PushChannel(channelID, packet); err != nil {
 			log.Errorln(err)
 		}
 	}
 	return nil
 }
 
-func shutdown() error {
+// Stop 关闭服务、从注册中心注销并断开与依赖服务的连接
+func (c *Container) Stop(ctx context.Context) error {
 	if !atomic.CompareAndSwapUint32(&c.state, stateStart, stateClosed) {
 		return errors.New("container already shutdown")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
+	// deregiste services from services registrer center first, so no new traffic comes in
+	if err := c.Drain(); err != nil {
+		log.Warnf("deregister %s failed: %v", c.Srv.ServiceID(), err)
+	}
+	close(c.done)
 	//close server elegantly
 	err := c.Srv.ShutDown(ctx)
 	if err != nil {
 		return err
 	}
-
-	// deregiste services from services registrer center
-	err = c.Naming.Deregister(c.Srv.ServiceID())
-	if err != nil {
-		return err
-	}
 	// unsubscribe services change
 	for dep := range c.deps {
 		_ = c.Naming.Unsubscribe(dep)
 	}
+	if c.broker != nil {
+		c.unsubscribe()
+	}
+	// close clients of dependencies
+	for _, clients := range c.srvClients {
+		for _, srv := range clients.Services() {
+			if cli, ok := clients.Get(srv.ServiceID()); ok {
+				cli.Close()
+			}
+		}
+	}
+	c.Lock()
+	if c.monitor != nil {
+		_ = c.monitor.Shutdown(ctx)
+	}
+	c.Unlock()
 
 	log.Infoln("shutdown")
 	return nil
 }
 
-func lookup(serviceName string, header *pkt.Header, selector Selector) (cim.Client, error) {
+func (c *Container) lookup(serviceName string, header *pkt.Header, selector Selector) (cim.Client, error) {
 	clients, ok := c.srvClients[serviceName]
 	if !ok {
 		return nil, fmt.Errorf("services %s not found", serviceName)
 	}
-	srvs := clients.Services(KeyServiceState, StateAdult)
+	srvs := c.warmUp(c.latest(clients.Services()), selector, header.ChannelID)
 	if len(srvs) == 0 {
-		return nil, fmt.Errorf("no services found")
+		return nil, fmt.Errorf("%w of %s", ErrNoService, serviceName)
+	}
+	healthy := c.healthy(serviceName, srvs)
+	if len(healthy) == 0 {
+		// 所有节点都处于熔断状态时，仍然从全部节点中选择
+		log.Warnf("all services of %s are unavailable, select from all", serviceName)
+		healthy = srvs
 	}
-	id := selector.Lookup(header, srvs)
+	id := selector.Lookup(header, healthy)
 	if cli, ok := clients.Get(id); ok {
+		c.breaker(serviceName, id).acquire(time.Now())
 		return cli, nil
 	}
 	return nil, errors.New("no client found")
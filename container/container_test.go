@@ -0,0 +1,744 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cim "cirno-im"
+	"cirno-im/broker/local"
+	"cirno-im/naming"
+	"cirno-im/naming/consul"
+	"cirno-im/naming/consul/consultest"
+	"cirno-im/tcp"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+// memNaming 进程内的注册中心
+type memNaming struct {
+	sync.Mutex
+	services  map[string]map[string]cim.ServiceRegistration
+	callbacks map[string]func([]naming.Event)
+	last      map[string][]cim.ServiceRegistration
+	queue     chan func()
+}
+
+func newMemNaming() *memNaming {
+	n := &memNaming{
+		services:  make(map[string]map[string]cim.ServiceRegistration),
+		callbacks: make(map[string]func([]naming.Event)),
+		last:      make(map[string][]cim.ServiceRegistration),
+		queue:     make(chan func(), 100),
+	}
+	// 按顺序在锁之外回调
+	go func() {
+		for f := range n.queue {
+			f()
+		}
+	}()
+	return n
+}
+
+func (n *memNaming) list(name string) []cim.ServiceRegistration {
+	list := make([]cim.ServiceRegistration, 0)
+	for _, s := range n.services[name] {
+		meta := make(map[string]string, len(s.GetMetadata()))
+		for k, v := range s.GetMetadata() {
+			meta[k] = v
+		}
+		list = append(list, &naming.DefaultService{
+			Id:       s.ServiceID(),
+			Name:     s.ServiceName(),
+			Address:  s.PublicAddress(),
+			Port:     s.PublicPort(),
+			Protocol: s.GetProtocol(),
+			Meta:     meta,
+		})
+	}
+	return list
+}
+
+func (n *memNaming) notify(name string) {
+	list := n.list(name)
+	events := naming.Diff(n.last[name], list)
+	n.last[name] = list
+	if cb, ok := n.callbacks[name]; ok && len(events) > 0 {
+		n.queue <- func() { cb(events) }
+	}
+}
+
+func (n *memNaming) Find(name string, tags ...string) ([]cim.ServiceRegistration, error) {
+	n.Lock()
+	defer n.Unlock()
+	return n.list(name), nil
+}
+
+func (n *memNaming) Subscribe(name string, callback func([]naming.Event)) error {
+	n.Lock()
+	defer n.Unlock()
+	n.callbacks[name] = callback
+	n.last[name] = n.list(name)
+	return nil
+}
+
+func (n *memNaming) Unsubscribe(name string) error {
+	n.Lock()
+	defer n.Unlock()
+	delete(n.callbacks, name)
+	return nil
+}
+
+func (n *memNaming) Register(s cim.ServiceRegistration) error {
+	n.Lock()
+	defer n.Unlock()
+	if n.services[s.ServiceName()] == nil {
+		n.services[s.ServiceName()] = make(map[string]cim.ServiceRegistration)
+	}
+	n.services[s.ServiceName()][s.ServiceID()] = s
+	n.notify(s.ServiceName())
+	return nil
+}
+
+func (n *memNaming) Deregister(id string) error {
+	n.Lock()
+	defer n.Unlock()
+	for name, services := range n.services {
+		if _, ok := services[id]; ok {
+			delete(services, id)
+			n.notify(name)
+		}
+	}
+	return nil
+}
+
+type testDialer struct {
+	serviceID string
+}
+
+func (d *testDialer) DialAndHandshake(ctx cim.DialerContext) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", ctx.Address, ctx.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	bts, _ := proto.Marshal(&pkt.InnerHandshakeRequest{
+		ServiceID: PoolMemberID(d.serviceID, ctx.Index),
+		Namespace: ctx.Namespace,
+	})
+	if err = tcp.WriteFrame(conn, cim.OpBinary, bts); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// testHandler 逻辑服务，把自己的ServiceID作为响应返回给调用方
+type testHandler struct {
+	ct *Container
+}
+
+func (h *testHandler) Accept(conn cim.Conn, timeout time.Duration) (string, cim.Meta, error) {
+	frame, err := conn.ReadFrame()
+	if err != nil {
+		return "", nil, err
+	}
+	var req pkt.InnerHandshakeRequest
+	if err := proto.Unmarshal(frame.GetPayload(), &req); err != nil {
+		return "", nil, err
+	}
+	if err := h.ct.CheckNamespace(req.Namespace); err != nil {
+		return "", nil, err
+	}
+	return req.ServiceID, nil, nil
+}
+
+func (h *testHandler) Receive(agent cim.Agent, payload []byte) {
+	packet, err := pkt.MustReadLogicPkt(bytes.NewBuffer(payload))
+	if err != nil {
+		return
+	}
+	from, _ := packet.GetString(wire.MetaCallFrom)
+	resp := pkt.NewFrom(&packet.Header)
+	resp.Flag = pkt.Flag_Response
+	resp.Meta = nil
+	resp.WriteBody(&pkt.ErrorResponse{Message: h.ct.Srv.ServiceID()})
+	resp.AddStringMeta(wire.MetaDestChannels, packet.ChannelID)
+	resp.AddStringMeta(wire.MetaCallFrom, from)
+	_ = h.ct.Push(from, resp)
+}
+
+func (h *testHandler) DisConnect(id string) error { return nil }
+
+func freePort(t *testing.T) int {
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer lst.Close()
+	return lst.Addr().(*net.TCPAddr).Port
+}
+
+func newTestContainer(t *testing.T, ns naming.Naming, id, name string, deps ...string) *Container {
+	return newNamespaceContainer(t, ns, "", id, name, deps...)
+}
+
+func newNamespaceContainer(t *testing.T, ns naming.Naming, namespace, id, name string, deps ...string) *Container {
+	port := freePort(t)
+	srv := tcp.NewServer(fmt.Sprintf("127.0.0.1:%d", port), &naming.DefaultService{
+		Id:        id,
+		Name:      name,
+		Namespace: namespace,
+		Address:   "127.0.0.1",
+		Port:      port,
+		Protocol:  string(wire.ProtocolTCP),
+	})
+	ct := New()
+	ct.SetWarmUp(0)
+	handler := &testHandler{ct: ct}
+	srv.SetAcceptor(handler)
+	srv.SetMessageListener(handler)
+	srv.SetStateListener(handler)
+	assert.Nil(t, ct.Init(srv, deps...))
+	ct.SetServiceNaming(ns)
+	ct.SetDialer(&testDialer{serviceID: id})
+	return ct
+}
+
+// newBrokerContainers 启动通过本地broker通信的chat01和gateway01，测试结束时停止
+func newBrokerContainers(t *testing.T) (chat01, gateway *Container) {
+	ns := newMemNaming()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	chat01 = newTestContainer(t, ns, "chat01", wire.SNChat)
+	gateway = newTestContainer(t, ns, "gateway01", "wgateway", wire.SNChat)
+	for _, ct := range []*Container{chat01, gateway} {
+		b, err := local.New(dir)
+		assert.Nil(t, err)
+		t.Cleanup(func() { b.Close() })
+		var listener cim.MessageListener
+		if ct == chat01 {
+			listener = &testHandler{ct: ct}
+		}
+		ct.SetBroker(b, listener)
+		assert.Nil(t, ct.Start(ctx))
+	}
+	t.Cleanup(func() {
+		for _, ct := range []*Container{gateway, chat01} {
+			assert.Nil(t, ct.Stop(ctx))
+		}
+	})
+	return chat01, gateway
+}
+
+func TestContainers(t *testing.T) {
+	ns := newMemNaming()
+	ctx := context.Background()
+
+	chat01 := newTestContainer(t, ns, "chat01", wire.SNChat)
+	chat02 := newTestContainer(t, ns, "chat02", wire.SNChat)
+	gateway := newTestContainer(t, ns, "gateway01", "wgateway", wire.SNChat)
+	gateway.SetPoolSize(3)
+	for _, ct := range []*Container{chat01, chat02, gateway} {
+		assert.Nil(t, ct.Start(ctx))
+	}
+
+	assert.Eventually(t, func() bool {
+		srvs := gateway.warmUp(gateway.srvClients[wire.SNChat].Services(), gateway.selector, "")
+		return len(srvs) == 2
+	}, time.Second*5, time.Millisecond*50)
+
+	gateway.SetSelector(NewConsistentHashSelector(DefaultReplicas))
+	hits := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		packet := pkt.New("chat.user.talk", pkt.WithChannel(fmt.Sprintf("channel%d", i)))
+		resp, err := gateway.Request(ctx, wire.SNChat, packet)
+		assert.Nil(t, err)
+		var body pkt.ErrorResponse
+		assert.Nil(t, resp.ReadBody(&body))
+		hits[body.Message]++
+	}
+	assert.Equal(t, 2, len(hits))
+
+	// 每个逻辑服务都有来自网关的3个连接
+	for _, ct := range []*Container{chat01, chat02} {
+		assert.Eventually(t, func() bool {
+			return len(ct.channels.All()) == 3
+		}, time.Second, time.Millisecond*50)
+	}
+	// 连接池中的连接断开之后自动替换
+	ch, ok := chat01.channels.Get(PoolMemberID("gateway01", 1))
+	assert.True(t, ok)
+	_ = ch.Close()
+	assert.Eventually(t, func() bool {
+		ch2, ok := chat01.channels.Get(PoolMemberID("gateway01", 1))
+		return ok && ch2 != ch
+	}, time.Second*3, time.Millisecond*50)
+
+	for _, ct := range []*Container{gateway, chat01, chat02} {
+		assert.Nil(t, ct.Stop(ctx))
+	}
+	assert.NotNil(t, gateway.Stop(ctx))
+}
+
+type pushServer struct {
+	cim.Server
+	sync.Mutex
+	pushed map[string][]*pkt.LogicPkt
+}
+
+func (s *pushServer) ServiceID() string            { return "gateway01" }
+func (s *pushServer) ServiceName() string          { return "wgateway" }
+func (s *pushServer) SetChannelMap(cim.ChannelMap) {}
+
+func (s *pushServer) Push(id string, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	packet, _ := pkt.MustReadLogicPkt(bytes.NewBuffer(data))
+	s.pushed[id] = append(s.pushed[id], packet)
+	return nil
+}
+
+func (s *pushServer) get(id string) []*pkt.LogicPkt {
+	s.Lock()
+	defer s.Unlock()
+	return s.pushed[id]
+}
+
+func TestRetry(t *testing.T) {
+	srv := &pushServer{pushed: map[string][]*pkt.LogicPkt{}}
+	ct := New()
+	assert.Nil(t, ct.Init(srv, wire.SNChat))
+	ct.srvClients[wire.SNChat] = NewClients(10)
+	ct.SetRetry(1, time.Millisecond*200)
+	defer close(ct.done)
+
+	err := ct.Forward(wire.SNChat, pkt.New(wire.CommandChatUserTalk, pkt.WithChannel("ch1"), pkt.WithSequence(1)))
+	assert.Nil(t, err)
+	// 缓冲区已满
+	err = ct.Forward(wire.SNChat, pkt.New(wire.CommandChatUserTalk, pkt.WithChannel("ch2"), pkt.WithSequence(2)))
+	assert.Equal(t, ErrRetryOverflow, err)
+	assert.Equal(t, 1, len(srv.get("ch2")))
+	assert.Equal(t, pkt.Status_SystemException, srv.get("ch2")[0].Status)
+
+	// 超时
+	assert.Eventually(t, func() bool {
+		return len(srv.get("ch1")) == 1
+	}, time.Second, time.Millisecond*50)
+	assert.Equal(t, pkt.Status_SystemException, srv.get("ch1")[0].Status)
+	assert.Equal(t, uint32(1), srv.get("ch1")[0].Sequence)
+}
+
+// routerHandler 使用cim.Router处理请求，响应由Context.Resp发出
+type routerHandler struct {
+	r  *cim.Router
+	ct *Container
+}
+
+type nopStorage struct {
+	cim.SessionStorage
+}
+
+func (h *routerHandler) Receive(agent cim.Agent, payload []byte) {
+	packet, err := pkt.MustReadLogicPkt(bytes.NewBuffer(payload))
+	if err != nil {
+		return
+	}
+	// 会话中的网关与调用方不同，响应应当按照call.from返回给调用方
+	session := &pkt.Session{ChannelID: packet.ChannelID, GateID: "gateway02"}
+	_ = h.r.Serve(packet, h, nopStorage{}, session)
+}
+
+func (h *routerHandler) Push(gateway string, channels []string, p *pkt.LogicPkt) error {
+	p.AddStringMeta(wire.MetaDestChannels, strings.Join(channels, ","))
+	return h.ct.Push(gateway, p)
+}
+
+func TestRequestWithRouter(t *testing.T) {
+	ns := newMemNaming()
+	ctx := context.Background()
+
+	chat01 := newTestContainer(t, ns, "chat01", wire.SNChat)
+	r := cim.NewRouter()
+	r.Handle(wire.CommandChatUserTalk, func(c cim.Context) {
+		_ = c.Resp(pkt.Status_Success, &pkt.MessageResponse{MessageID: int64(c.Header().Sequence)})
+	})
+	chat01.Srv.SetMessageListener(&routerHandler{r: r, ct: chat01})
+	gateway := newTestContainer(t, ns, "gateway01", "wgateway", wire.SNChat)
+	for _, ct := range []*Container{chat01, gateway} {
+		assert.Nil(t, ct.Start(ctx))
+	}
+	defer func() {
+		for _, ct := range []*Container{gateway, chat01} {
+			_ = ct.Stop(ctx)
+		}
+	}()
+	assert.Eventually(t, func() bool {
+		return len(gateway.srvClients[wire.SNChat].Services()) == 1
+	}, time.Second*3, time.Millisecond*50)
+
+	// 并发的请求按照Sequence匹配到各自的响应
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			packet := pkt.New(wire.CommandChatUserTalk, pkt.WithChannel(fmt.Sprintf("channel%d", i)))
+			resp, err := gateway.Request(ctx, wire.SNChat, packet)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, pkt.Flag_Response, resp.Flag)
+			var body pkt.MessageResponse
+			assert.Nil(t, resp.ReadBody(&body))
+			assert.Equal(t, int64(packet.Sequence), body.MessageID)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestBrokerTransport(t *testing.T) {
+	ctx := context.Background()
+	_, gateway := newBrokerContainers(t)
+
+	packet := pkt.New("chat.user.talk", pkt.WithChannel("channel1"))
+	resp, err := gateway.Request(ctx, wire.SNChat, packet)
+	assert.Nil(t, err)
+	var body pkt.ErrorResponse
+	assert.Nil(t, resp.ReadBody(&body))
+	assert.Equal(t, "chat01", body.Message)
+}
+
+func TestDispatchCalls(t *testing.T) {
+	ct := newTestContainer(t, newMemNaming(), "gateway01", "wgateway")
+	respChan := make(chan *pkt.LogicPkt, 1)
+	ct.calls.Store(callKey{channelID: "channel1", sequence: 5}, respChan)
+
+	// 客户端请求的响应即使Sequence相同也不会交给服务间调用
+	resp := pkt.New("chat.user.talk", pkt.WithChannel("channel1"), pkt.WithSequence(5))
+	resp.Flag = pkt.Flag_Response
+	ct.dispatch(resp)
+	assert.Len(t, respChan, 0)
+
+	call := pkt.New("chat.user.talk", pkt.WithChannel("channel1"), pkt.WithSequence(5))
+	call.Flag = pkt.Flag_Response
+	call.AddStringMeta(wire.MetaCallFrom, "gateway01")
+	ct.dispatch(call)
+	assert.Len(t, respChan, 1)
+
+	// 请求方已经超时的响应被丢弃，不会推送给用户
+	late := pkt.New("chat.user.talk", pkt.WithChannel("channel1"), pkt.WithSequence(5))
+	late.Flag = pkt.Flag_Response
+	late.AddStringMeta(wire.MetaCallFrom, "gateway01")
+	late.AddStringMeta(wire.MetaDestServer, "gateway01")
+	late.AddStringMeta(wire.MetaDestChannels, "channel1")
+	ct.dispatch(late)
+	_, ok := late.GetString(wire.MetaDestServer)
+	assert.True(t, ok)
+}
+
+func TestConsole(t *testing.T) {
+	_, gateway := newBrokerContainers(t)
+	gateway.SetAdminToken("secret")
+	mux := http.NewServeMux()
+	gateway.handleConsole(mux)
+	admin := httptest.NewServer(mux)
+	defer admin.Close()
+
+	post := func(body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, admin.URL+"/admin/console", strings.NewReader(body))
+		req.Header.Set(AdminTokenHeader, "secret")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return resp
+	}
+	resp := post(`{"command":"chat.user.talk","channel_id":"ch1","body":{"type":1}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = post(`{"command":"chat.user.talk","channel_id":"ch1","dest":"test2","body":{"type":1,"body":"hello"}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var result ConsoleResponse
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, pkt.Status_Success.String(), result.Status)
+
+	req, _ := http.NewRequest(http.MethodGet, admin.URL+"/admin/commands", nil)
+	req.Header.Set(AdminTokenHeader, "secret")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	var commands []CommandInfo
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&commands))
+	assert.NotEmpty(t, commands)
+}
+
+func TestAdmin(t *testing.T) {
+	ct := New()
+	ct.channels = newPoolChannels(cim.NewChannels(10))
+	mux := http.NewServeMux()
+	ct.handleAdmin(mux)
+	admin := httptest.NewServer(mux)
+	defer admin.Close()
+
+	local, remote := net.Pipe()
+	ch := cim.NewChannel("ch1", cim.Meta{"account": "test1", "app": "cim"}, tcp.NewConn(local), nil)
+	go func() { _ = ch.ReadLoop(nil) }()
+	ct.channels.Add(ch)
+
+	do := func(method, path string) *http.Response {
+		req, _ := http.NewRequest(method, admin.URL+path, nil)
+		req.Header.Set(AdminTokenHeader, "secret")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return resp
+	}
+	// 没有设置token时不开放
+	resp := do(http.MethodGet, "/admin/channels")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	ct.SetAdminToken("secret")
+	resp = do(http.MethodGet, "/admin/channels?account=test1")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var list []ChannelInfo
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&list))
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, "ch1", list[0].ID)
+	assert.Equal(t, "cim", list[0].Meta["app"])
+
+	resp = do(http.MethodGet, "/admin/channels?account=test2")
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&list))
+	assert.Equal(t, 0, len(list))
+
+	ct.SetAdminToken("other")
+	resp = do(http.MethodGet, "/admin/channels/ch1")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	ct.SetAdminToken("secret")
+
+	// 踢下线之后连接被关闭
+	assert.Eventually(t, func() bool {
+		return do(http.MethodDelete, "/admin/channels/ch1").StatusCode == http.StatusNoContent
+	}, time.Second, time.Millisecond*10)
+	_, err := remote.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+}
+
+func TestReadiness(t *testing.T) {
+	ns := newMemNaming()
+	ctx := context.Background()
+	ct := newTestContainer(t, ns, "gateway01", "wgateway", wire.SNChat)
+	mux := http.NewServeMux()
+	ct.handleHealth(mux)
+	monitor := httptest.NewServer(mux)
+	defer monitor.Close()
+
+	get := func(path string) (int, *Readiness) {
+		resp, err := http.Get(monitor.URL + path)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		var res Readiness
+		_ = json.NewDecoder(resp.Body).Decode(&res)
+		return resp.StatusCode, &res
+	}
+	code, _ := get("/health/live")
+	assert.Equal(t, http.StatusOK, code)
+
+	// 还没有启动
+	code, res := get("/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, res.Started)
+
+	// 依赖的服务还没有连接
+	assert.Nil(t, ct.Start(ctx))
+	code, res = get("/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, 0, res.Dependencies[wire.SNChat].Connected)
+
+	chat01 := newTestContainer(t, ns, "chat01", wire.SNChat)
+	assert.Nil(t, chat01.Start(ctx))
+	assert.Eventually(t, func() bool {
+		code, _ := get("/health/ready")
+		return code == http.StatusOK
+	}, time.Second*3, time.Millisecond*50)
+
+	ct.AddHealthCheck("storage", func(ctx context.Context) error { return fmt.Errorf("connection refused") })
+	code, res = get("/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "connection refused", res.Checks["storage"])
+	ct.AddHealthCheck("storage", func(ctx context.Context) error { return nil })
+
+	// 下线时立即从注册中心注销
+	assert.Nil(t, ct.Drain())
+	code, res = get("/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.True(t, res.Draining)
+	found, _ := ns.Find("wgateway")
+	assert.Equal(t, 0, len(found))
+
+	assert.Nil(t, ct.Stop(ctx))
+	assert.Nil(t, chat01.Stop(ctx))
+}
+
+func TestServiceEvents(t *testing.T) {
+	consulSrv := consultest.NewServer()
+	defer consulSrv.Close()
+	ns, err := consul.NewNaming(consulSrv.URL)
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	chat01 := newTestContainer(t, ns, "chat01", wire.SNChat)
+	chat02 := newTestContainer(t, ns, "chat02", wire.SNChat)
+	gateway := newTestContainer(t, ns, "gateway01", "wgateway", wire.SNChat)
+	for _, ct := range []*Container{chat01, chat02, gateway} {
+		assert.Nil(t, ct.Start(ctx))
+	}
+	clients := gateway.srvClients[wire.SNChat]
+	assert.Eventually(t, func() bool {
+		return len(clients.Services()) == 2
+	}, time.Second*5, time.Millisecond*50)
+	cli, _ := clients.Get("chat01")
+
+	// metadata更新之后选择节点时使用新的metadata，连接保持不变
+	_ = ns.Register(&naming.DefaultService{
+		Id: "chat01", Name: wire.SNChat, Address: "127.0.0.1", Port: chat01.Srv.PublicPort(),
+		Protocol: string(wire.ProtocolTCP), Meta: map[string]string{"zone": "zone_2"},
+	})
+	assert.Eventually(t, func() bool {
+		for _, srv := range gateway.latest(clients.Services()) {
+			if srv.ServiceID() == "chat01" {
+				return srv.GetMetadata()["zone"] == "zone_2"
+			}
+		}
+		return false
+	}, time.Second*3, time.Millisecond*50)
+	cli2, _ := clients.Get("chat01")
+	assert.Equal(t, cli, cli2)
+
+	// 健康检查失败的服务被删除，连接关闭并且不再重连
+	consulSrv.SetStatus("chat02", api.HealthCritical)
+	assert.Eventually(t, func() bool {
+		_, ok := clients.Get("chat02")
+		return !ok
+	}, time.Second*3, time.Millisecond*50)
+	time.Sleep(time.Second)
+	_, ok := clients.Get("chat02")
+	assert.False(t, ok)
+
+	// 地址变化之后重新连接到新的地址
+	_ = ns.Register(&naming.DefaultService{
+		Id: "chat01", Name: wire.SNChat, Address: "127.0.0.1", Port: chat02.Srv.PublicPort(),
+		Protocol: string(wire.ProtocolTCP),
+	})
+	assert.Eventually(t, func() bool {
+		packet := pkt.New("chat.user.talk", pkt.WithChannel("channel1"))
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond*200)
+		defer cancel()
+		resp, err := gateway.Request(ctx, wire.SNChat, packet)
+		if err != nil {
+			return false
+		}
+		var body pkt.ErrorResponse
+		_ = resp.ReadBody(&body)
+		return body.Message == "chat02"
+	}, time.Second*5, time.Millisecond*100)
+
+	for _, ct := range []*Container{gateway, chat01, chat02} {
+		assert.Nil(t, ct.Stop(ctx))
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	consulSrv := consultest.NewServer()
+	defer consulSrv.Close()
+	ns, err := consul.NewNaming(consulSrv.URL)
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	chat01 := newNamespaceContainer(t, ns, "staging", "chat01", wire.SNChat)
+	chat02 := newNamespaceContainer(t, ns, "loadtest", "chat02", wire.SNChat)
+	gateway := newNamespaceContainer(t, ns, "staging", "gateway01", "wgateway", wire.SNChat)
+	for _, ct := range []*Container{chat01, chat02, gateway} {
+		assert.Nil(t, ct.Start(ctx))
+	}
+	defer func() {
+		for _, ct := range []*Container{gateway, chat01, chat02} {
+			_ = ct.Stop(ctx)
+		}
+	}()
+
+	srvs, _ := ns.Find(wire.SNChat, consul.NamespaceTag("loadtest"))
+	assert.Len(t, srvs, 1)
+	assert.Equal(t, "loadtest", srvs[0].GetNamespace())
+
+	// 只连接同一个命名空间中的服务
+	clients := gateway.srvClients[wire.SNChat]
+	assert.Eventually(t, func() bool {
+		return len(clients.Services()) == 1
+	}, time.Second*3, time.Millisecond*50)
+	time.Sleep(time.Millisecond * 200)
+	_, ok := clients.Get("chat02")
+	assert.False(t, ok)
+
+	// 建立连接时检查对方的命名空间
+	_, err = gateway.newClient(srvs[0], 0)
+	assert.ErrorIs(t, err, ErrNamespaceMismatch)
+
+	// 对方冒充同一个命名空间时，服务端拒绝握手
+	fake := &naming.DefaultService{
+		Id: "chat01", Name: wire.SNChat, Namespace: "loadtest",
+		Address: "127.0.0.1", Port: chat01.Srv.PublicPort(), Protocol: string(wire.ProtocolTCP),
+	}
+	cli, err := chat02.newClient(fake, 0)
+	assert.Nil(t, err)
+	defer cli.Close()
+	_, err = cli.Read()
+	assert.NotNil(t, err)
+	_, ok = chat01.channels.Get("chat02")
+	assert.False(t, ok)
+}
+
+func TestSequence(t *testing.T) {
+	srv := &pushServer{pushed: map[string][]*pkt.LogicPkt{}}
+	ct := New()
+	assert.Nil(t, ct.Init(srv))
+	ct.SetPushHistory(4)
+
+	ct.OpenSequence("ch1", 1)
+	assert.False(t, ct.CheckSequence("ch1", 1))
+	assert.True(t, ct.CheckSequence("ch1", 3))
+	assert.True(t, ct.CheckSequence("ch1", 2))
+	assert.False(t, ct.CheckSequence("ch1", 3))
+	// 没有开启序号的channel不去重
+	assert.True(t, ct.CheckSequence("ch2", 1))
+	assert.True(t, ct.CheckSequence("ch2", 1))
+
+	for i := 0; i < 6; i++ {
+		assert.Nil(t, ct.PushChannel("ch1", pkt.New(wire.CommandChatUserTalk, pkt.WithChannel("ch1"))))
+	}
+	assert.Nil(t, ct.PushChannel("ch2", pkt.New(wire.CommandChatUserTalk, pkt.WithChannel("ch2"))))
+	pushed := srv.get("ch1")
+	assert.Equal(t, 6, len(pushed))
+	for i, p := range pushed {
+		assert.Equal(t, uint32(i+1), p.PushSeq)
+	}
+	assert.Equal(t, uint32(0), srv.get("ch2")[0].PushSeq)
+
+	// 只保留了最近的4条
+	first, last, err := ct.Resend("ch1", 1, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), first)
+	assert.Equal(t, uint32(6), last)
+	pushed = srv.get("ch1")[6:]
+	assert.Equal(t, 2, len(pushed))
+	assert.Equal(t, uint32(3), pushed[0].PushSeq)
+	assert.Equal(t, uint32(4), pushed[1].PushSeq)
+
+	ct.CloseSequence("ch1")
+	_, _, err = ct.Resend("ch1", 1, 0)
+	assert.Equal(t, ErrNoSequence, err)
+}
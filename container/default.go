@@ -0,0 +1,146 @@
+package container
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	cim "cirno-im"
+	"cirno-im/broker"
+	"cirno-im/naming"
+	"cirno-im/wire/pkt"
+)
+
+// c 进程默认的容器，包级别的函数都作用于它
+var c = New()
+
+func Default() *Container {
+	return c
+}
+
+func Init(srv cim.Server, deps ...string) error {
+	return c.Init(srv, deps...)
+}
+
+func SetDialer(dialer cim.Dialer) {
+	c.SetDialer(dialer)
+}
+
+// EnableMonitor start
+func EnableMonitor(listen string) {
+	c.EnableMonitor(listen)
+}
+
+func SetSelector(selector Selector) {
+	c.SetSelector(selector)
+}
+
+// SetRetry 开启默认容器的重试缓冲区
+func SetRetry(size int, window time.Duration) {
+	c.SetRetry(size, window)
+}
+
+// SetWarmUp 设置默认容器中新服务的预热时长
+func SetWarmUp(d time.Duration) {
+	c.SetWarmUp(d)
+}
+
+// Ready 声明默认容器中的服务已经就绪
+func Ready() error {
+	return c.Ready()
+}
+
+// SetPoolSize 设置默认容器到每个依赖服务节点的连接数
+func SetPoolSize(size int) {
+	c.SetPoolSize(size)
+}
+
+// SetBroker 默认容器通过消息队列转发消息
+func SetBroker(b broker.Broker, listener cim.MessageListener) {
+	c.SetBroker(b, listener)
+}
+
+// SetAdminToken 设置默认容器管理接口的token
+func SetAdminToken(token string) {
+	c.SetAdminToken(token)
+}
+
+// AddHealthCheck 给默认容器添加一个就绪检查项
+func AddHealthCheck(name string, check HealthCheck) {
+	c.AddHealthCheck(name, check)
+}
+
+// Drain 默认容器进入下线状态
+func Drain() error {
+	return c.Drain()
+}
+
+func SetServiceNaming(name naming.Naming) {
+	c.SetServiceNaming(name)
+}
+
+// Start 启动默认容器，并阻塞到收到退出信号
+func Start() error {
+	if err := c.Start(context.Background()); err != nil {
+		return err
+	}
+	// 等待中断信号
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	log.Infoln("exit signal:", <-ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return c.Stop(ctx)
+}
+
+// Push message to server
+func Push(server string, p *pkt.LogicPkt) error {
+	return c.Push(server, p)
+}
+
+// Forward message to services
+func Forward(serviceName string, packet *pkt.LogicPkt) error {
+	return c.Forward(serviceName, packet)
+}
+
+// ForwardWithSelector forward data to the specified node of services which is chosen by selector
+func ForwardWithSelector(serviceName string, packet *pkt.LogicPkt, selector Selector) error {
+	return c.ForwardWithSelector(serviceName, packet, selector)
+}
+
+// Request forward the packet to a node of services and wait for the response
+func Request(ctx context.Context, serviceName string, packet *pkt.LogicPkt) (*pkt.LogicPkt, error) {
+	return c.Request(ctx, serviceName, packet)
+}
+
+// Inflight 返回默认容器转发到服务serviceID但还未响应的消息数
+func Inflight(serviceID string) int {
+	return c.Inflight(serviceID)
+}
+
+// Unhealthy 返回默认容器中被标记为不健康的依赖服务
+func Unhealthy() []string {
+	return c.Unhealthy()
+}
+
+func NewSelector(name string) (Selector, error) {
+	return c.NewSelector(name)
+}
+
+// SetChannelCodec 设置推送给channel的消息使用的body变换方式
+func SetChannelCodec(channelID string, codec *pkt.Codec) {
+	c.SetChannelCodec(channelID, codec)
+}
+
+// SetPushHistory 设置默认容器中每个channel保留的推送数
+func SetPushHistory(size int) {
+	c.SetPushHistory(size)
+}
+
+// CheckNamespace 检查对方服务是否与当前服务在同一个命名空间
+func CheckNamespace(namespace string) error {
+	return c.CheckNamespace(namespace)
+}
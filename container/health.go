@@ -0,0 +1,140 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// healthCheckTimeout 单个健康检查的超时时间
+const healthCheckTimeout = time.Second
+
+// HealthCheck 就绪检查项，比如存储是否可以访问
+type HealthCheck func(ctx context.Context) error
+
+// DependencyHealth 依赖服务的连接情况
+type DependencyHealth struct {
+	Registered int      `json:"registered"`
+	Connected  int      `json:"connected"`
+	Unhealthy  []string `json:"unhealthy,omitempty"`
+}
+
+// Readiness 就绪检查的结果
+type Readiness struct {
+	Ready        bool                        `json:"ready"`
+	Draining     bool                        `json:"draining"`
+	Started      bool                        `json:"started"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+	Checks       map[string]string           `json:"checks"`
+}
+
+type healthChecks struct {
+	sync.RWMutex
+	checks map[string]HealthCheck
+}
+
+// AddHealthCheck 添加一个就绪检查项
+func (c *Container) AddHealthCheck(name string, check HealthCheck) {
+	c.checks.Lock()
+	defer c.checks.Unlock()
+	if c.checks.checks == nil {
+		c.checks.checks = make(map[string]HealthCheck)
+	}
+	c.checks.checks[name] = check
+}
+
+// Drain 进入下线状态：就绪检查失败，并且立即从注册中心注销，不再接收新的流量
+func (c *Container) Drain() error {
+	if !atomic.CompareAndSwapUint32(&c.draining, 0, 1) {
+		return nil
+	}
+	log.Infoln("draining")
+	if c.Naming == nil || c.Srv == nil {
+		return nil
+	}
+	return c.Naming.Deregister(c.Srv.ServiceID())
+}
+
+// Readiness 检查当前节点是否可以接收流量
+func (c *Container) Readiness(ctx context.Context) *Readiness {
+	res := &Readiness{
+		Draining:     atomic.LoadUint32(&c.draining) == 1,
+		Started:      atomic.LoadUint32(&c.state) == stateStart,
+		Dependencies: make(map[string]DependencyHealth),
+		Checks:       make(map[string]string),
+	}
+	res.Ready = res.Started && !res.Draining
+
+	// 使用消息队列时不与依赖服务直接连接
+	if c.broker == nil {
+		for name := range c.deps {
+			dep := DependencyHealth{}
+			c.RLock()
+			dep.Registered = len(c.registered[name])
+			clients, ok := c.srvClients[name]
+			c.RUnlock()
+			if ok {
+				dep.Connected = len(clients.Services())
+			}
+			c.unhealthy.Range(func(key, val any) bool {
+				if val.(string) == name {
+					dep.Unhealthy = append(dep.Unhealthy, key.(string))
+				}
+				return true
+			})
+			sort.Strings(dep.Unhealthy)
+			if dep.Connected == 0 {
+				res.Ready = false
+			}
+			res.Dependencies[name] = dep
+		}
+	}
+
+	c.checks.RLock()
+	checks := make(map[string]HealthCheck, len(c.checks.checks))
+	for name, check := range c.checks.checks {
+		checks[name] = check
+	}
+	c.checks.RUnlock()
+	for name, check := range checks {
+		ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		err := check(ctx)
+		cancel()
+		if err != nil {
+			res.Checks[name] = err.Error()
+			res.Ready = false
+		} else {
+			res.Checks[name] = "ok"
+		}
+	}
+	return res
+}
+
+func (c *Container) handleHealth(mux *http.ServeMux) {
+	// liveness 只要进程还能响应就返回成功
+	mux.HandleFunc("/health/live", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"status": "up"})
+	})
+	ready := func(w http.ResponseWriter, r *http.Request) {
+		res := c.Readiness(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !res.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}
+	mux.HandleFunc("/health/ready", ready)
+	// 兼容已经配置在注册中心的地址
+	mux.HandleFunc("/health", ready)
+	mux.HandleFunc("POST /admin/drain", c.admin(func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Drain(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
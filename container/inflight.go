@@ -0,0 +1,102 @@
+package container
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cirno-im/constants"
+	"cirno-im/wire/pkt"
+)
+
+// inflight 记录已转发到某个服务但还未收到响应的消息，超过DefaultRequestWait未响应的消息不再计数
+type inflight struct {
+	sync.Mutex
+	pending map[string]time.Time
+}
+
+func inflightKey(header *pkt.Header) string {
+	return fmt.Sprintf("%s:%d", header.ChannelID, header.Sequence)
+}
+
+func (i *inflight) add(key string) {
+	i.Lock()
+	defer i.Unlock()
+	i.pending[key] = time.Now()
+}
+
+// done 返回消息的发送时间
+func (i *inflight) done(key string) (time.Time, bool) {
+	i.Lock()
+	defer i.Unlock()
+	sent, ok := i.pending[key]
+	delete(i.pending, key)
+	return sent, ok
+}
+
+// expire 清理超时未响应的消息，返回清理的数量
+// 有些请求本来就没有响应（比如登出），超时未响应只清理记录不记为失败，失败以转发错误为准
+func (i *inflight) expire() int {
+	i.Lock()
+	defer i.Unlock()
+	expired := 0
+	deadline := time.Now().Add(-constants.DefaultRequestWait)
+	for key, sent := range i.pending {
+		if sent.Before(deadline) {
+			delete(i.pending, key)
+			expired++
+		}
+	}
+	return expired
+}
+
+func (i *inflight) count() int {
+	i.expire()
+	i.Lock()
+	defer i.Unlock()
+	return len(i.pending)
+}
+
+func (c *Container) trackInflight(serviceID string, header *pkt.Header) {
+	val, loaded := c.inflights.LoadOrStore(serviceID, &inflight{pending: make(map[string]time.Time)})
+	if !loaded {
+		go c.expireLoop(val.(*inflight))
+	}
+	val.(*inflight).add(inflightKey(header))
+}
+
+// expireLoop 定期清理超时未响应的消息，不在选择节点时扫描
+func (c *Container) expireLoop(i *inflight) {
+	ticker := time.NewTicker(constants.DefaultRequestWait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		i.expire()
+	}
+}
+
+// doneInflight 收到响应，返回消息从发送到响应的耗时
+func (c *Container) doneInflight(serviceID string, header *pkt.Header) (time.Duration, bool) {
+	val, ok := c.inflights.Load(serviceID)
+	if !ok {
+		return 0, false
+	}
+	sent, ok := val.(*inflight).done(inflightKey(header))
+	if !ok {
+		return 0, false
+	}
+	return time.Since(sent), true
+}
+
+// Inflight 返回已转发到服务serviceID但还未响应的消息数
+func (c *Container) Inflight(serviceID string) int {
+	val, ok := c.inflights.Load(serviceID)
+	if !ok {
+		return 0
+	}
+	return val.(*inflight).count()
+}
@@ -0,0 +1,33 @@
+package container
+
+import (
+	"fmt"
+
+	cim "cirno-im"
+	"cirno-im/wire/pkt"
+)
+
+// FindChannels 返回本地meta满足match的channel
+func (c *Container) FindChannels(match func(meta cim.Meta) bool) []string {
+	var ids []string
+	for _, ch := range c.channels.All() {
+		if meta := ch.GetMetadata(); meta != nil && match(meta) {
+			ids = append(ids, ch.ID())
+		}
+	}
+	return ids
+}
+
+// KickOut 推送packet后关闭本地的channel，写循环会先发完已经推送的消息再断开连接
+func (c *Container) KickOut(channelID string, packet *pkt.LogicPkt) error {
+	ch, ok := c.channels.Get(channelID)
+	if !ok {
+		return fmt.Errorf("channel %s not found", channelID)
+	}
+	if packet != nil {
+		if err := c.PushChannel(channelID, packet); err != nil {
+			log.WithField("func", "KickOut").Warn(err)
+		}
+	}
+	return ch.Close()
+}
@@ -0,0 +1,39 @@
+package container
+
+import (
+	"hash/crc32"
+
+	cim "cirno-im"
+	"cirno-im/wire/pkt"
+)
+
+// LeastInflightSelector 选择未响应消息数最少的服务，数量相同时按ChannelID哈希
+type LeastInflightSelector struct {
+	inflight func(serviceID string) int
+}
+
+func NewLeastInflightSelector(inflight func(serviceID string) int) *LeastInflightSelector {
+	return &LeastInflightSelector{
+		inflight: inflight,
+	}
+}
+
+func (s *LeastInflightSelector) Lookup(header *pkt.Header, srvs []cim.Service) string {
+	least := make([]cim.Service, 0, len(srvs))
+	min := -1
+	for _, srv := range srvs {
+		n := s.inflight(srv.ServiceID())
+		switch {
+		case min == -1 || n < min:
+			min = n
+			least = append(least[:0], srv)
+		case n == min:
+			least = append(least, srv)
+		}
+	}
+	if len(least) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(header.ChannelID))
+	return least[int(hash%uint32(len(least)))].ServiceID()
+}
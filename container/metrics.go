@@ -10,4 +10,52 @@ var messageOutFlowBytes = promauto.NewCounterVec(prometheus.CounterOpts{
 	Name:      "message_out_flow_bytes",
 	Help:      "网关下发的消息字节数",
 }, []string{"command"})
-   
\ No newline at end of file
+   
+var reconnectTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cim",
+	Name:      "dependency_reconnect_total",
+	Help:      "依赖服务的重连次数",
+}, []string{"service_name", "service_id", "result"})
+
+var dependencyUnhealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "cim",
+	Name:      "dependency_unhealthy",
+	Help:      "依赖服务是否处于不可达状态",
+}, []string{"service_name", "service_id"})
+
+var breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "cim",
+	Name:      "dependency_breaker_state",
+	Help:      "依赖服务节点的熔断状态，0关闭 1断开 2半开",
+}, []string{"service_name", "service_id"})
+
+var dependencyFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cim",
+	Name:      "dependency_failures_total",
+	Help:      "依赖服务节点的失败次数",
+}, []string{"service_name", "service_id", "reason"})
+
+var outlierEjections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cim",
+	Name:      "dependency_outlier_ejections_total",
+	Help:      "依赖服务节点因响应过慢被剔除的次数",
+}, []string{"service_name", "service_id"})
+
+var dependencyLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "cim",
+	Name:      "dependency_latency_seconds",
+	Help:      "依赖服务的响应时间",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"service_name"})
+
+var retryBuffered = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "cim",
+	Name:      "retry_buffered",
+	Help:      "重试缓冲区中等待转发的消息数",
+}, []string{"service_name"})
+
+var retryDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cim",
+	Name:      "retry_dropped_total",
+	Help:      "重试缓冲区溢出或者超时丢弃的消息数",
+}, []string{"service_name", "reason"})
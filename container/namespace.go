@@ -0,0 +1,17 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNamespaceMismatch 对方服务与当前服务不在同一个命名空间
+var ErrNamespaceMismatch = errors.New("namespace mismatch")
+
+// CheckNamespace 检查对方服务的命名空间，服务间握手的两端都需要检查
+func (c *Container) CheckNamespace(namespace string) error {
+	if namespace != c.Srv.GetNamespace() {
+		return fmt.Errorf("%w: %q is not %q", ErrNamespaceMismatch, namespace, c.Srv.GetNamespace())
+	}
+	return nil
+}
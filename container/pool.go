@@ -0,0 +1,222 @@
+package container
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cim "cirno-im"
+)
+
+// PoolMemberID 连接池中第index个连接握手时使用的ID，主连接使用服务自己的ID
+func PoolMemberID(serviceID string, index int) string {
+	if index == 0 {
+		return serviceID
+	}
+	return fmt.Sprintf("%s#%d", serviceID, index)
+}
+
+// parsePoolMemberID 解析连接池成员的ID，返回服务ID和序号
+func parsePoolMemberID(id string) (string, int) {
+	i := strings.LastIndexByte(id, '#')
+	if i <= 0 {
+		return id, 0
+	}
+	index, err := strconv.Atoi(id[i+1:])
+	if err != nil || index <= 0 {
+		return id, 0
+	}
+	return id[:i], index
+}
+
+// indexDialer 在DialerContext中带上连接在池中的序号和当前服务的命名空间
+type indexDialer struct {
+	cim.Dialer
+	index     int
+	namespace string
+}
+
+func (d *indexDialer) DialAndHandshake(ctx cim.DialerContext) (net.Conn, error) {
+	ctx.Index = d.index
+	ctx.Namespace = d.namespace
+	return d.Dialer.DialAndHandshake(ctx)
+}
+
+// pool 到一个依赖服务节点的多个连接，members[0]为主连接
+type pool struct {
+	sync.RWMutex
+	members []cim.Client
+}
+
+func (p *pool) get(i int) cim.Client {
+	p.RLock()
+	defer p.RUnlock()
+	return p.members[i]
+}
+
+func (p *pool) set(i int, cli cim.Client) {
+	p.Lock()
+	defer p.Unlock()
+	p.members[i] = cli
+}
+
+// pick 按照key的哈希选择一个连接，保证同一个channel的消息顺序
+func (p *pool) pick(key string) cim.Client {
+	p.RLock()
+	defer p.RUnlock()
+	i := crc32.ChecksumIEEE([]byte(key)) % uint32(len(p.members))
+	if cli := p.members[i]; cli != nil {
+		return cli
+	}
+	return p.members[0]
+}
+
+// size 返回已连接的数量
+func (p *pool) size() int {
+	p.RLock()
+	defer p.RUnlock()
+	n := 0
+	for _, cli := range p.members {
+		if cli != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (p *pool) close() {
+	p.Lock()
+	defer p.Unlock()
+	for i := 1; i < len(p.members); i++ {
+		if p.members[i] != nil {
+			p.members[i].Close()
+			p.members[i] = nil
+		}
+	}
+}
+
+// SetPoolSize 设置到每个依赖服务节点的连接数
+func (c *Container) SetPoolSize(size int) {
+	if size < 1 {
+		size = 1
+	}
+	c.poolSize = size
+}
+
+// pick 从连接池中选择发送消息的连接
+func (c *Container) pick(cli cim.Client, channelID string) cim.Client {
+	val, ok := c.pools.Load(cli.ServiceID())
+	if !ok {
+		return cli
+	}
+	return val.(*pool).pick(channelID)
+}
+
+// buildPool 为主连接建立连接池中其余的连接
+func (c *Container) buildPool(cli cim.Client, service cim.ServiceRegistration) {
+	if c.poolSize <= 1 {
+		return
+	}
+	p := &pool{members: make([]cim.Client, c.poolSize)}
+	p.members[0] = cli
+	c.pools.Store(service.ServiceID(), p)
+	for i := 1; i < c.poolSize; i++ {
+		go c.fillPool(p, service, i)
+	}
+}
+
+// fillPool 建立连接池中第index个连接，连接断开后自动替换，直到主连接断开
+func (c *Container) fillPool(p *pool, service cim.ServiceRegistration, index int) {
+	id := service.ServiceID()
+	log := log.WithField("func", "fillPool").WithField("service_id", id)
+	for attempt := 0; ; {
+		if val, ok := c.pools.Load(id); !ok || val.(*pool) != p {
+			return
+		}
+		member, err := c.newClient(service, index)
+		if err != nil {
+			log.Warnf("connect member %d failed: %v", index, err)
+			select {
+			case <-time.After(backoff(attempt)):
+			case <-c.done:
+				return
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+		if val, ok := c.pools.Load(id); !ok || val.(*pool) != p {
+			member.Close()
+			return
+		}
+		p.set(index, member)
+		if err := c.readLoop(member); err != nil {
+			log.Infof("member %d closed: %v", index, err)
+		}
+		p.set(index, nil)
+		member.Close()
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+	}
+}
+
+// poolChannels 按照服务ID索引对端连接池中的channel
+type poolChannels struct {
+	cim.ChannelMap
+	sync.RWMutex
+	members map[string]map[int]string
+}
+
+func newPoolChannels(channels cim.ChannelMap) *poolChannels {
+	return &poolChannels{
+		ChannelMap: channels,
+		members:    make(map[string]map[int]string),
+	}
+}
+
+func (p *poolChannels) Add(channel cim.Channel) {
+	p.ChannelMap.Add(channel)
+	base, index := parsePoolMemberID(channel.ID())
+	p.Lock()
+	defer p.Unlock()
+	if p.members[base] == nil {
+		p.members[base] = make(map[int]string)
+	}
+	p.members[base][index] = channel.ID()
+}
+
+func (p *poolChannels) Remove(id string) {
+	p.ChannelMap.Remove(id)
+	base, index := parsePoolMemberID(id)
+	p.Lock()
+	defer p.Unlock()
+	delete(p.members[base], index)
+	if len(p.members[base]) == 0 {
+		delete(p.members, base)
+	}
+}
+
+// pick 按照key的哈希从服务serviceID的连接池中选择一个channel
+func (p *poolChannels) pick(serviceID, key string) string {
+	p.RLock()
+	defer p.RUnlock()
+	members := p.members[serviceID]
+	if len(members) <= 1 {
+		return serviceID
+	}
+	indexes := make([]int, 0, len(members))
+	for index := range members {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	i := crc32.ChecksumIEEE([]byte(key)) % uint32(len(indexes))
+	return members[indexes[i]]
+}
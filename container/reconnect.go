@@ -0,0 +1,114 @@
+package container
+
+import (
+	"math/rand"
+	"time"
+
+	cim "cirno-im"
+	"cirno-im/constants"
+)
+
+// register 记录依赖服务在注册中心的最新注册信息，返回之前的注册信息
+func (c *Container) register(serviceName string, service cim.ServiceRegistration) cim.ServiceRegistration {
+	c.Lock()
+	defer c.Unlock()
+	registered, ok := c.registered[serviceName]
+	if !ok {
+		registered = make(map[string]cim.ServiceRegistration)
+		c.registered[serviceName] = registered
+	}
+	old := registered[service.ServiceID()]
+	registered[service.ServiceID()] = service
+	return old
+}
+
+// deregister 服务从注册中心删除
+func (c *Container) deregister(serviceName, id string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.registered[serviceName], id)
+	c.states.Delete(id)
+}
+
+func (c *Container) registration(serviceName, id string) (cim.ServiceRegistration, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	service, ok := c.registered[serviceName][id]
+	return service, ok
+}
+
+// backoff 指数退避，并在[d/2, d)之间加入随机抖动
+func backoff(attempt int) time.Duration {
+	d := constants.DefaultReconnectBackoff << uint(attempt)
+	if d <= 0 || d > constants.DefaultReconnectMaxBackoff {
+		d = constants.DefaultReconnectMaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// reconnect 服务仍然在注册中心时，按退避策略使用最新的注册信息重新建立连接
+func (c *Container) reconnect(clients ClientMap, service cim.ServiceRegistration) {
+	var (
+		id   = service.ServiceID()
+		name = service.ServiceName()
+	)
+	if _, loaded := c.reconnecting.LoadOrStore(id, struct{}{}); loaded {
+		return
+	}
+	defer c.reconnecting.Delete(id)
+
+	log := log.WithField("func", "reconnect").WithField("service_id", id)
+	for attempt := 0; ; attempt++ {
+		wait := backoff(attempt)
+		log.Infof("reconnect to %s after %v, attempt %d", service.DialURL(), wait, attempt+1)
+		select {
+		case <-time.After(wait):
+		case <-c.done:
+			return
+		}
+		latest, ok := c.registration(name, id)
+		if !ok {
+			log.Infof("service %s is deregistered, stop reconnecting", id)
+			c.setHealthy(name, id)
+			c.breakers.Delete(id)
+			breakerState.DeleteLabelValues(name, id)
+			return
+		}
+		if _, ok := clients.Get(id); ok {
+			c.setHealthy(name, id)
+			return
+		}
+		// 使用最新的注册信息，服务的地址可能已经变化
+		service = latest
+		_, err := c.buildClient(clients, service)
+		if err == nil {
+			reconnectTotal.WithLabelValues(name, id, "success").Inc()
+			log.Infof("reconnected to %s", service.DialURL())
+			c.setHealthy(name, id)
+			return
+		}
+		reconnectTotal.WithLabelValues(name, id, "failure").Inc()
+		log.Warnf("reconnect failed: %v", err)
+		if attempt+1 == constants.DefaultUnhealthyAttempts {
+			log.Errorf("service %s is unreachable after %d attempts, mark it unhealthy", id, attempt+1)
+			c.unhealthy.Store(id, name)
+			dependencyUnhealthy.WithLabelValues(name, id).Set(1)
+		}
+	}
+}
+
+func (c *Container) setHealthy(name, id string) {
+	if _, ok := c.unhealthy.LoadAndDelete(id); ok {
+		dependencyUnhealthy.WithLabelValues(name, id).Set(0)
+	}
+}
+
+// Unhealthy 返回连续重连失败而被标记为不健康的依赖服务
+func (c *Container) Unhealthy() []string {
+	ids := make([]string, 0)
+	c.unhealthy.Range(func(key, _ any) bool {
+		ids = append(ids, key.(string))
+		return true
+	})
+	return ids
+}
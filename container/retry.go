@@ -0,0 +1,114 @@
+package container
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"cirno-im/constants"
+	"cirno-im/wire/pkt"
+)
+
+// ErrNoService 依赖服务当前没有可用的节点
+var ErrNoService = errors.New("no services found")
+
+// ErrRetryOverflow 重试缓冲区已满
+var ErrRetryOverflow = errors.New("retry buffer overflow")
+
+// retryInterval 缓冲区中的消息重新转发的间隔
+const retryInterval = time.Millisecond * 100
+
+type retryEntry struct {
+	serviceName string
+	packet      *pkt.LogicPkt
+	selector    Selector
+	deadline    time.Time
+}
+
+// retryQueue 一个依赖服务的重试缓冲区
+type retryQueue struct {
+	sync.Mutex
+	entries []*retryEntry
+}
+
+// SetRetry 开启重试缓冲区，没有可用节点时每个服务最多缓存size个消息，每个消息最多等待window
+func (c *Container) SetRetry(size int, window time.Duration) {
+	if window <= 0 {
+		window = constants.DefaultRetryWindow
+	}
+	c.retrySize = size
+	c.retryWindow = window
+}
+
+// retry 把消息放入重试缓冲区
+func (c *Container) retry(serviceName string, packet *pkt.LogicPkt, selector Selector) error {
+	val, loaded := c.retries.LoadOrStore(serviceName, &retryQueue{})
+	queue := val.(*retryQueue)
+	if !loaded {
+		go c.retryLoop(serviceName, queue)
+	}
+
+	queue.Lock()
+	defer queue.Unlock()
+	if len(queue.entries) >= c.retrySize {
+		retryDropped.WithLabelValues(serviceName, "overflow").Inc()
+		c.respSystemException(packet)
+		return ErrRetryOverflow
+	}
+	queue.entries = append(queue.entries, &retryEntry{
+		serviceName: serviceName,
+		packet:      packet,
+		selector:    selector,
+		deadline:    time.Now().Add(c.retryWindow),
+	})
+	retryBuffered.WithLabelValues(serviceName).Set(float64(len(queue.entries)))
+	log.Debugf("buffer message %s of %s for retrying", packet.Command, packet.ChannelID)
+	return nil
+}
+
+func (c *Container) retryLoop(serviceName string, queue *retryQueue) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		queue.Lock()
+		now := time.Now()
+		remain := queue.entries[:0]
+		for i, entry := range queue.entries {
+			if now.After(entry.deadline) {
+				retryDropped.WithLabelValues(serviceName, "expired").Inc()
+				c.respSystemException(entry.packet)
+				continue
+			}
+			err := c.forward(entry.serviceName, entry.packet, entry.selector)
+			if errors.Is(err, ErrNoService) {
+				// 仍然没有可用节点，保留剩下的消息并保持原有顺序
+				remain = append(remain, queue.entries[i:]...)
+				break
+			}
+			if err != nil {
+				log.Warnf("retry %s of %s failed: %v", entry.packet.Command, entry.packet.ChannelID, err)
+			}
+		}
+		for i := len(remain); i < len(queue.entries); i++ {
+			queue.entries[i] = nil
+		}
+		queue.entries = remain
+		retryBuffered.WithLabelValues(serviceName).Set(float64(len(queue.entries)))
+		queue.Unlock()
+	}
+}
+
+// respSystemException 直接给客户端回复SystemException
+func (c *Container) respSystemException(packet *pkt.LogicPkt) {
+	resp := pkt.NewFrom(&packet.Header)
+	resp.Status = pkt.Status_SystemException
+	resp.Flag = pkt.Flag_Response
+	if err := c.PushChannel(packet.ChannelID, resp); err != nil {
+		log.Warnf("response to %s failed: %v", packet.ChannelID, err)
+	}
+}
@@ -0,0 +1,85 @@
+package container
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	cim "cirno-im"
+	"cirno-im/wire/pkt"
+	"github.com/cespare/xxhash/v2"
+)
+
+// DefaultReplicas 每个服务在哈希环上的虚拟节点数
+const DefaultReplicas = 160
+
+// ConsistentHashSelector 一致性哈希，服务上下线时只有少量的channel会被迁移到其它节点
+type ConsistentHashSelector struct {
+	replicas int
+
+	sync.RWMutex
+	key   string
+	ring  []uint64
+	nodes map[uint64]string
+}
+
+func NewConsistentHashSelector(replicas int) *ConsistentHashSelector {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &ConsistentHashSelector{
+		replicas: replicas,
+	}
+}
+
+func (s *ConsistentHashSelector) Lookup(header *pkt.Header, srvs []cim.Service) string {
+	ring, nodes := s.build(srvs)
+	if len(ring) == 0 {
+		return ""
+	}
+	hash := xxhash.Sum64String(header.ChannelID)
+	i := sort.Search(len(ring), func(i int) bool { return ring[i] >= hash })
+	if i == len(ring) {
+		i = 0
+	}
+	return nodes[ring[i]]
+}
+
+// build 服务列表没有变化时复用已有的哈希环
+func (s *ConsistentHashSelector) build(srvs []cim.Service) ([]uint64, map[uint64]string) {
+	ids := make([]string, 0, len(srvs))
+	for _, srv := range srvs {
+		ids = append(ids, srv.ServiceID())
+	}
+	sort.Strings(ids)
+	key := strings.Join(ids, ",")
+
+	s.RLock()
+	if s.key == key && s.ring != nil {
+		ring, nodes := s.ring, s.nodes
+		s.RUnlock()
+		return ring, nodes
+	}
+	s.RUnlock()
+
+	ring := make([]uint64, 0, len(ids)*s.replicas)
+	nodes := make(map[uint64]string, len(ids)*s.replicas)
+	for _, id := range ids {
+		for i := 0; i < s.replicas; i++ {
+			// crc32对只有末尾几位不同的虚拟节点分布不均匀
+			hash := xxhash.Sum64String(id + "#" + strconv.Itoa(i))
+			if _, ok := nodes[hash]; ok {
+				continue
+			}
+			nodes[hash] = id
+			ring = append(ring, hash)
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i] < ring[j] })
+
+	s.Lock()
+	s.key, s.ring, s.nodes = key, ring, nodes
+	s.Unlock()
+	return ring, nodes
+}
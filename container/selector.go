@@ -3,10 +3,18 @@ package container
 import (
 	"cirno-im"
 	"cirno-im/wire/pkt"
+	"fmt"
 	"hash/crc32"
 )
 
-//HashCode generated a hash code
+const (
+	SelectorHash           = "hash"
+	SelectorConsistentHash = "consistent_hash"
+	SelectorWeighted       = "weighted_round_robin"
+	SelectorLeastInflight  = "least_inflight"
+)
+
+// HashCode generated a hash code
 func HashCode(key string) (int, error) {
 	ieee := crc32.NewIEEE()
 	_, err := ieee.Write([]byte(key))
@@ -18,5 +26,21 @@ func HashCode(key string) (int, error) {
 
 // Selector is used to select a Service
 type Selector interface {
-	Lookup(*pkt.Header,[]cim.Service) string
+	Lookup(*pkt.Header, []cim.Service) string
+}
+
+// NewSelector 根据名称创建容器内置的Selector，名称为空时使用HashSelector
+func (c *Container) NewSelector(name string) (Selector, error) {
+	switch name {
+	case "", SelectorHash:
+		return &HashSelector{}, nil
+	case SelectorConsistentHash:
+		return NewConsistentHashSelector(DefaultReplicas), nil
+	case SelectorWeighted:
+		return NewWeightedSelector(), nil
+	case SelectorLeastInflight:
+		return NewLeastInflightSelector(c.Inflight), nil
+	default:
+		return nil, fmt.Errorf("unknown selector %s", name)
+	}
 }
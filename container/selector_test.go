@@ -0,0 +1,190 @@
+package container
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	cim "cirno-im"
+	"cirno-im/constants"
+	"cirno-im/naming"
+	"cirno-im/wire/pkt"
+	"github.com/stretchr/testify/assert"
+)
+
+func services(n int) []cim.Service {
+	srvs := make([]cim.Service, 0, n)
+	for i := 0; i < n; i++ {
+		srvs = append(srvs, &naming.DefaultService{
+			Id:   fmt.Sprintf("chat%02d", i),
+			Name: "chat",
+			Meta: map[string]string{},
+		})
+	}
+	return srvs
+}
+
+func TestConsistentHashSelector(t *testing.T) {
+	s := NewConsistentHashSelector(DefaultReplicas)
+	srvs := services(5)
+
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		ch := fmt.Sprintf("channel%d", i)
+		before[ch] = s.Lookup(&pkt.Header{ChannelID: ch}, srvs)
+	}
+	// 同一个服务集合，顺序不影响结果
+	reversed := make([]cim.Service, 0, len(srvs))
+	for i := len(srvs) - 1; i >= 0; i-- {
+		reversed = append(reversed, srvs[i])
+	}
+	for ch, id := range before {
+		assert.Equal(t, id, s.Lookup(&pkt.Header{ChannelID: ch}, reversed))
+	}
+
+	// 下线一个服务，只有它上面的channel被迁移
+	removed := srvs[2].ServiceID()
+	for ch, id := range before {
+		got := s.Lookup(&pkt.Header{ChannelID: ch}, append(srvs[:2:2], srvs[3:]...))
+		if id != removed {
+			assert.Equal(t, id, got)
+		} else {
+			assert.NotEqual(t, removed, got)
+		}
+	}
+}
+
+func TestConsistentHashSpread(t *testing.T) {
+	s := NewConsistentHashSelector(DefaultReplicas)
+	srvs := services(5)
+	hits := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		hits[s.Lookup(&pkt.Header{ChannelID: fmt.Sprintf("channel%d", i)}, srvs)]++
+	}
+	assert.Len(t, hits, len(srvs))
+	// 每个节点分到的channel与平均值的偏差不超过20%
+	for id, n := range hits {
+		assert.InDelta(t, 2000, n, 400, id)
+	}
+}
+
+func TestWeightedSelector(t *testing.T) {
+	srvs := services(3)
+	srvs[0].GetMetadata()[MetaKeyWeight] = "5"
+	srvs[1].GetMetadata()[MetaKeyWeight] = "1"
+	srvs[2].GetMetadata()[MetaKeyWeight] = "0"
+
+	s := NewWeightedSelector()
+	hits := make(map[string]int)
+	for i := 0; i < 60; i++ {
+		hits[s.Lookup(&pkt.Header{}, srvs)]++
+	}
+	assert.Equal(t, 50, hits["chat00"])
+	assert.Equal(t, 10, hits["chat01"])
+	assert.Equal(t, 0, hits["chat02"])
+}
+
+func TestLeastInflightSelector(t *testing.T) {
+	inflights := map[string]int{"chat00": 3, "chat01": 1, "chat02": 2}
+	s := NewLeastInflightSelector(func(id string) int { return inflights[id] })
+	assert.Equal(t, "chat01", s.Lookup(&pkt.Header{ChannelID: "ch1"}, services(3)))
+
+	c := New()
+	header := &pkt.Header{ChannelID: "ch1", Sequence: 1}
+	c.trackInflight("chat01", header)
+	c.trackInflight("chat01", &pkt.Header{ChannelID: "ch1", Sequence: 2})
+	assert.Equal(t, 2, c.Inflight("chat01"))
+	c.doneInflight("chat01", header)
+	assert.Equal(t, 1, c.Inflight("chat01"))
+	assert.Equal(t, 0, c.Inflight("chat02"))
+}
+
+func TestBreaker(t *testing.T) {
+	c := New()
+	srvs := services(4)
+	for i := 0; i < BreakerFailures; i++ {
+		c.reportFailure("chat", "chat00", "send_error")
+	}
+	healthy := c.healthy("chat", srvs)
+	assert.Equal(t, 3, len(healthy))
+	assert.NotEqual(t, "chat00", healthy[0].ServiceID())
+
+	// 冷却结束后进入半开状态，试探成功后恢复
+	b := c.breaker("chat", "chat00")
+	b.openedAt = b.openedAt.Add(-BreakerCooldown)
+	assert.Equal(t, 4, len(c.healthy("chat", srvs)))
+	b.acquire(time.Now())
+	assert.Equal(t, breakerHalfOpen, b.state)
+	assert.Equal(t, 3, len(c.healthy("chat", srvs)))
+	c.reportSuccess("chat", "chat00", time.Millisecond)
+	assert.Equal(t, breakerClosed, b.state)
+
+	// 响应时间明显高于其它节点的被剔除
+	c.reportSuccess("chat", "chat01", time.Millisecond*10)
+	c.reportSuccess("chat", "chat02", time.Millisecond*10)
+	c.reportSuccess("chat", "chat03", time.Second)
+	healthy = c.healthy("chat", srvs)
+	assert.Equal(t, 3, len(healthy))
+	assert.Equal(t, breakerOpen, c.breaker("chat", "chat03").state)
+}
+
+func TestBreakerIgnoresUnanswered(t *testing.T) {
+	c := New()
+	srvs := services(2)
+	for i := 0; i < BreakerFailures*2; i++ {
+		c.trackInflight("chat00", &pkt.Header{ChannelID: "ch1", Sequence: uint32(i + 1)})
+	}
+	val, _ := c.inflights.Load("chat00")
+	i := val.(*inflight)
+	i.Lock()
+	for key := range i.pending {
+		i.pending[key] = time.Now().Add(-constants.DefaultRequestWait * 2)
+	}
+	i.Unlock()
+	// 选择节点时不扫描inflight，超时的记录由后台清理
+	assert.Equal(t, 2, len(c.healthy("chat", srvs)))
+	assert.Equal(t, BreakerFailures*2, len(i.pending))
+	// 没有响应的请求超时后只清理记录，不会让节点熔断
+	assert.Equal(t, BreakerFailures*2, i.expire())
+	assert.Equal(t, 0, c.Inflight("chat00"))
+	assert.Equal(t, breakerClosed, c.breaker("chat", "chat00").state)
+}
+
+func TestWarmUp(t *testing.T) {
+	c := New()
+	c.SetWarmUp(time.Second * 10)
+	srvs := services(3)
+	for _, srv := range srvs[:2] {
+		c.states.Store(srv.ServiceID(), &serviceState{readyAt: time.Now().Add(-time.Minute)})
+	}
+	// 新服务声明了young，还未就绪
+	young := &naming.DefaultService{Id: "chat02", Name: "chat", Meta: map[string]string{KeyServiceState: StateYoung}}
+	c.updateState(young)
+	assert.Equal(t, 2, len(c.warmUp(srvs, &HashSelector{}, "channel1")))
+
+	young.Meta[KeyServiceState] = StateAdult
+	c.updateState(young)
+	c.states.Store("chat02", &serviceState{readyAt: time.Now().Add(-time.Second * 5)})
+	f := c.factor("chat02", time.Now())
+	assert.InDelta(t, 0.5, f, 0.05)
+
+	// 权重按照预热进度调整
+	weighted := c.warmUp(srvs, NewWeightedSelector(), "channel1")
+	assert.Equal(t, 3, len(weighted))
+	assert.Equal(t, 100, Weight(weighted[0]))
+	assert.InDelta(t, 50, Weight(weighted[2]), 5)
+
+	// 同一个channel每次得到相同的节点，大约一半的channel可以使用预热中的节点
+	admitted := 0
+	for i := 0; i < 1000; i++ {
+		channelID := fmt.Sprintf("channel%d", i)
+		res := c.warmUp(srvs, &HashSelector{}, channelID)
+		for j := 0; j < 3; j++ {
+			assert.Equal(t, len(res), len(c.warmUp(srvs, &HashSelector{}, channelID)))
+		}
+		if len(res) == 3 {
+			admitted++
+		}
+	}
+	assert.InDelta(t, 500, admitted, 100)
+}
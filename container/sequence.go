@@ -0,0 +1,120 @@
+package container
+
+import (
+	"errors"
+	"sync"
+
+	"cirno-im/wire/pkt"
+)
+
+// ErrNoSequence channel没有开启序号，见OpenSequence
+var ErrNoSequence = errors.New("channel sequence is not opened")
+
+type pushRecord struct {
+	seq     uint32
+	payload []byte
+}
+
+// channelSequence 一个channel的请求去重窗口和最近的推送记录
+type channelSequence struct {
+	sync.Mutex
+	window pkt.ReplayWindow
+	last   uint32
+	// history 环形缓冲区，pushSeq为n的推送保存在n%len(history)
+	history []pushRecord
+}
+
+// first 仍保留的最早的pushSeq
+func (s *channelSequence) first() uint32 {
+	size := uint32(len(s.history))
+	if s.last < size {
+		return 1
+	}
+	return s.last - size + 1
+}
+
+// SetPushHistory 设置每个channel保留的推送数，只对之后开启的channel生效
+func (c *Container) SetPushHistory(size int) {
+	if size > 0 {
+		c.pushHistory = size
+	}
+}
+
+// OpenSequence 网关在channel登录时开启请求去重和推送序号，seq为登录包的Sequence
+func (c *Container) OpenSequence(channelID string, seq uint32) {
+	s := &channelSequence{history: make([]pushRecord, c.pushHistory)}
+	s.window.Accept(seq)
+	c.sequences.Store(channelID, s)
+}
+
+// CloseSequence 删除channel的去重窗口和推送记录
+func (c *Container) CloseSequence(channelID string) {
+	c.sequences.Delete(channelID)
+}
+
+// CheckSequence 客户端发来的请求第一次出现时返回true，重试发送的包返回false，没有开启序号的channel总是返回true
+func (c *Container) CheckSequence(channelID string, seq uint32) bool {
+	val, ok := c.sequences.Load(channelID)
+	if !ok {
+		return true
+	}
+	s := val.(*channelSequence)
+	s.Lock()
+	defer s.Unlock()
+	return s.window.Accept(seq)
+}
+
+// Resend 按顺序重新推送[from, to]范围内仍保留的推送，to为0表示到最新，返回仍保留的最早和最新的pushSeq
+func (c *Container) Resend(channelID string, from, to uint32) (first, last uint32, err error) {
+	val, ok := c.sequences.Load(channelID)
+	if !ok {
+		return 0, 0, ErrNoSequence
+	}
+	s := val.(*channelSequence)
+	s.Lock()
+	defer s.Unlock()
+	first, last = s.first(), s.last
+	if from < first {
+		from = first
+	}
+	if to == 0 || to > last {
+		to = last
+	}
+	for seq := from; seq <= to && seq != 0; seq++ {
+		record := s.history[seq%uint32(len(s.history))]
+		if record.seq != seq {
+			continue
+		}
+		if err = c.Srv.Push(channelID, record.payload); err != nil {
+			return first, last, err
+		}
+	}
+	return first, last, nil
+}
+
+// PushChannel 网关上序列化packet并推送给本地的channel，开启了序号的channel会给packet分配pushSeq并保留推送记录
+func (c *Container) PushChannel(channelID string, packet *pkt.LogicPkt) error {
+	val, ok := c.sequences.Load(channelID)
+	if !ok {
+		packet.PushSeq = 0
+		payload, err := c.channelPayload(channelID, packet)
+		if err != nil {
+			return err
+		}
+		messageOutFlowBytes.WithLabelValues(packet.Command).Add(float64(len(payload)))
+		return c.Srv.Push(channelID, payload)
+	}
+	s := val.(*channelSequence)
+	// 分配序号和写入channel在同一个锁中完成，客户端收到的pushSeq总是递增的
+	s.Lock()
+	defer s.Unlock()
+	packet.PushSeq = s.last + 1
+	payload, err := c.channelPayload(channelID, packet)
+	if err != nil {
+		return err
+	}
+	s.last = packet.PushSeq
+	s.history[s.last%uint32(len(s.history))] = pushRecord{seq: s.last, payload: payload}
+	messageOutFlowBytes.WithLabelValues(packet.Command).Add(float64(len(payload)))
+	return c.Srv.Push(channelID, payload)
+}
@@ -0,0 +1,81 @@
+package container
+
+import (
+	"bytes"
+	"fmt"
+
+	cim "cirno-im"
+	"cirno-im/broker"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+)
+
+const (
+	TransportTCP    = "tcp"
+	TransportBroker = "broker"
+)
+
+// SetBroker 通过消息队列转发消息，listener用来处理发给当前服务的消息，网关不需要设置
+func (c *Container) SetBroker(b broker.Broker, listener cim.MessageListener) {
+	c.broker = b
+	c.listener = listener
+}
+
+// subscribe 订阅发给当前节点的消息，以及发给当前服务的消息
+func (c *Container) subscribe() error {
+	err := c.broker.Subscribe(broker.GatewayTopic(c.Srv.ServiceID()), func(payload []byte) {
+		packet, err := pkt.MustReadLogicPkt(bytes.NewBuffer(payload))
+		if err != nil {
+			log.Errorln(err)
+			return
+		}
+		c.dispatch(packet)
+	})
+	if err != nil {
+		return err
+	}
+	if c.listener == nil {
+		return nil
+	}
+	return c.broker.Subscribe(broker.ServiceTopic(c.Srv.ServiceName()), func(payload []byte) {
+		packet, err := pkt.MustReadLogicPkt(bytes.NewBuffer(payload))
+		if err != nil {
+			log.Errorln(err)
+			return
+		}
+		server, ok := packet.GetString(wire.MetaDestServer)
+		if !ok {
+			log.Warnf("dest_server is nil in %s", &packet.Header)
+			return
+		}
+		c.listener.Receive(&brokerAgent{id: server, c: c}, payload)
+	})
+}
+
+func (c *Container) unsubscribe() {
+	_ = c.broker.Unsubscribe(broker.GatewayTopic(c.Srv.ServiceID()))
+	if c.listener != nil {
+		_ = c.broker.Unsubscribe(broker.ServiceTopic(c.Srv.ServiceName()))
+	}
+}
+
+// brokerAgent 消息的发送方，通过消息队列回复
+type brokerAgent struct {
+	id string
+	c  *Container
+}
+
+func (a *brokerAgent) ID() string { return a.id }
+
+func (a *brokerAgent) Push(payload []byte) error {
+	packet, err := pkt.MustReadLogicPkt(bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("push to %s: %w", a.id, err)
+	}
+	if _, ok := packet.GetString(wire.MetaDestChannels); !ok {
+		packet.AddStringMeta(wire.MetaDestChannels, packet.ChannelID)
+	}
+	return a.c.Push(a.id, packet)
+}
+
+func (a *brokerAgent) GetMetadata() cim.Meta { return nil }
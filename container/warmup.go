@@ -0,0 +1,156 @@
+package container
+
+import (
+	"hash/fnv"
+	"math"
+	"strconv"
+	"time"
+
+	cim "cirno-im"
+)
+
+// minWarmUpFactor 预热刚开始时的最小权重比例
+const minWarmUpFactor = 0.01
+
+// serviceState 依赖服务节点在注册中心的最新状态，更新时整体替换
+type serviceState struct {
+	meta map[string]string
+	// readyAt 节点就绪的时间，为零表示节点声明了young还未就绪
+	readyAt time.Time
+}
+
+// Weighted 按照服务metadata中的权重进行选择的Selector，预热期间容器会调低新服务的权重，而不是随机跳过它
+type Weighted interface {
+	Weighted() bool
+}
+
+// SetWarmUp 设置新服务的预热时长，预热期间服务的权重从0线性增加到完整权重
+func (c *Container) SetWarmUp(d time.Duration) {
+	c.warmup = d
+}
+
+// Ready 声明当前服务已经就绪，注册时metadata中KeyServiceState为young的服务需要调用它才会开始接收消息
+func (c *Container) Ready() error {
+	meta := c.Srv.GetMetadata()
+	if meta == nil || meta[KeyServiceState] != StateYoung {
+		return nil
+	}
+	meta[KeyServiceState] = StateAdult
+	return c.Naming.Register(c.Srv)
+}
+
+// updateState 根据注册中心的metadata更新节点的状态，节点可以通过KeyServiceState自己声明是否就绪
+func (c *Container) updateState(service cim.ServiceRegistration) {
+	meta := make(map[string]string, len(service.GetMetadata()))
+	for k, v := range service.GetMetadata() {
+		meta[k] = v
+	}
+	state := &serviceState{meta: meta}
+	if meta[KeyServiceState] != StateYoung {
+		state.readyAt = time.Now()
+		if val, ok := c.states.Load(service.ServiceID()); ok && !val.(*serviceState).readyAt.IsZero() {
+			state.readyAt = val.(*serviceState).readyAt
+		}
+	}
+	c.states.Store(service.ServiceID(), state)
+}
+
+// factor 返回节点当前的权重比例，未就绪时返回0
+func (c *Container) factor(id string, now time.Time) float64 {
+	val, ok := c.states.Load(id)
+	if !ok || val.(*serviceState).readyAt.IsZero() {
+		return 0
+	}
+	elapsed := now.Sub(val.(*serviceState).readyAt)
+	if c.warmup <= 0 || elapsed >= c.warmup {
+		return 1
+	}
+	f := float64(elapsed) / float64(c.warmup)
+	if f < minWarmUpFactor {
+		f = minWarmUpFactor
+	}
+	return f
+}
+
+// warmUp 过滤掉未就绪的节点，并按照预热进度调整节点被选中的概率，
+// 不按权重选择时由channelID决定是否使用预热中的节点，同一个channel的消息不会在节点之间来回切换
+func (c *Container) warmUp(srvs []cim.Service, selector Selector, channelID string) []cim.Service {
+	now := time.Now()
+	factors := make(map[string]float64, len(srvs))
+	ready := make([]cim.Service, 0, len(srvs))
+	warming := false
+	for _, srv := range srvs {
+		f := c.factor(srv.ServiceID(), now)
+		if f == 0 {
+			continue
+		}
+		if f < 1 {
+			warming = true
+		}
+		factors[srv.ServiceID()] = f
+		ready = append(ready, srv)
+	}
+	if !warming {
+		return ready
+	}
+
+	if w, ok := selector.(Weighted); ok && w.Weighted() {
+		res := make([]cim.Service, 0, len(ready))
+		for _, srv := range ready {
+			res = append(res, withWeight(srv, Weight(srv)*100, factors[srv.ServiceID()]))
+		}
+		return res
+	}
+	res := make([]cim.Service, 0, len(ready))
+	for _, srv := range ready {
+		if f := factors[srv.ServiceID()]; f >= 1 || admit(channelID, srv.ServiceID()) < f {
+			res = append(res, srv)
+		}
+	}
+	if len(res) == 0 {
+		return ready
+	}
+	return res
+}
+
+// admit 把channel和节点的hash归一化到[0,1)，小于预热比例的channel可以使用这个节点，比例增加时已经准入的channel保持不变
+func admit(channelID, serviceID string) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(channelID))
+	_, _ = h.Write([]byte{'#'})
+	_, _ = h.Write([]byte(serviceID))
+	return float64(h.Sum64()) / (math.MaxUint64 + 1.0)
+}
+
+// metaService 替换了metadata的服务
+type metaService struct {
+	cim.Service
+	meta map[string]string
+}
+
+func withWeight(srv cim.Service, weight int, factor float64) cim.Service {
+	meta := make(map[string]string, len(srv.GetMetadata())+1)
+	for k, v := range srv.GetMetadata() {
+		meta[k] = v
+	}
+	w := int(float64(weight) * factor)
+	if w < 1 && weight > 0 {
+		w = 1
+	}
+	meta[MetaKeyWeight] = strconv.Itoa(w)
+	return &metaService{Service: srv, meta: meta}
+}
+
+func (s *metaService) GetMetadata() map[string]string { return s.meta }
+
+// latest 使用注册中心最新的metadata替换建立连接时的metadata
+func (c *Container) latest(srvs []cim.Service) []cim.Service {
+	res := make([]cim.Service, 0, len(srvs))
+	for _, srv := range srvs {
+		if val, ok := c.states.Load(srv.ServiceID()); ok {
+			srv = &metaService{Service: srv, meta: val.(*serviceState).meta}
+		}
+		res = append(res, srv)
+	}
+	return res
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"strconv"
+	"sync"
+
+	cim "cirno-im"
+	"cirno-im/wire/pkt"
+)
+
+// MetaKeyWeight 服务注册时在metadata中声明的权重
+const MetaKeyWeight = "weight"
+
+// WeightedSelector 平滑加权轮询，权重读取自服务的metadata，缺省为1
+type WeightedSelector struct {
+	sync.Mutex
+	current map[string]int
+}
+
+func NewWeightedSelector() *WeightedSelector {
+	return &WeightedSelector{
+		current: make(map[string]int),
+	}
+}
+
+// Weighted 预热期间由容器调低新服务的权重
+func (s *WeightedSelector) Weighted() bool { return true }
+
+func (s *WeightedSelector) Lookup(header *pkt.Header, srvs []cim.Service) string {
+	s.Lock()
+	defer s.Unlock()
+
+	var (
+		total int
+		best  cim.Service
+		alive = make(map[string]struct{}, len(srvs))
+	)
+	for _, srv := range srvs {
+		id := srv.ServiceID()
+		alive[id] = struct{}{}
+		weight := Weight(srv)
+		total += weight
+		s.current[id] += weight
+		if best == nil || s.current[id] > s.current[best.ServiceID()] {
+			best = srv
+		}
+	}
+	// 清理已下线的服务
+	for id := range s.current {
+		if _, ok := alive[id]; !ok {
+			delete(s.current, id)
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	s.current[best.ServiceID()] -= total
+	return best.ServiceID()
+}
+
+// Weight 读取服务的权重
+func Weight(srv cim.Service) int {
+	val, ok := srv.GetMetadata()[MetaKeyWeight]
+	if !ok {
+		return 1
+	}
+	weight, err := strconv.Atoi(val)
+	if err != nil || weight < 0 {
+		return 1
+	}
+	return weight
+}
@@ -1,13 +1,19 @@
 package cim
 
 import (
+	"cirno-im/constants"
 	"cirno-im/logger"
+	"cirno-im/trace"
 	"cirno-im/wire"
 	"cirno-im/wire/pkt"
+	"context"
+	"errors"
 	"google.golang.org/protobuf/proto"
 	"sync"
 )
 
+var ErrRequestNotSupported = errors.New("err:dispatcher does not support request")
+
 var _ SessionStorage
 
 type Session interface {
@@ -25,12 +31,18 @@ type Session interface {
 type Context interface {
 	Dispatcher
 	SessionStorage
+	// Context 返回当前请求的context，其中包含了链路追踪的span
+	Context() context.Context
 	Header() *pkt.Header
 	ReadBody(val proto.Message) error
 	Session() Session
 	RespWithError(status pkt.Status, err error) error
 	Resp(status pkt.Status, body proto.Message) error
+	// RespStatus 返回已响应的状态码，未响应时为Success
+	RespStatus() pkt.Status
 	Dispatch(body proto.Message, recvs ...*Location) error
+	// Request 调用其它逻辑服务并等待响应，目标服务由command的前缀决定
+	Request(ctx context.Context, command string, body proto.Message, opts ...pkt.HeaderOption) (*pkt.LogicPkt, error)
 	Next()
 }
 
@@ -47,6 +59,8 @@ type ContextImpl struct {
 	index    int
 	request  *pkt.LogicPkt
 	session  Session
+	status   pkt.Status
+	ctx      context.Context
 }
 
 func BuildContext() Context {
@@ -66,6 +80,13 @@ func (c *ContextImpl) Next() {
 	f(c)
 }
 
+func (c *ContextImpl) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 func (c *ContextImpl) Header() *pkt.Header {
 	return &c.request.Header
 }
@@ -76,10 +97,10 @@ func (c *ContextImpl) ReadBody(val proto.Message) error {
 
 func (c *ContextImpl) Session() Session {
 	if c.session == nil {
-		server, _ := c.request.GetMeta(wire.MetaDestServer)
+		server, _ := c.request.GetString(wire.MetaDestServer)
 		c.session = &pkt.Session{
 			ChannelID: c.request.ChannelID,
-			GateID:    server.(string),
+			GateID:    server,
 			Tags:      []string{"AutoGenerated"},
 		}
 	}
@@ -95,14 +116,25 @@ func (c *ContextImpl) Resp(status pkt.Status, body proto.Message) error {
 	packet.Status = status
 	packet.WriteBody(body)
 	packet.Flag = pkt.Flag_Response
+	c.status = status
 	logger.Debugf("<-- Resp to %s command:%s  status: %v body: %s", c.Session().GetAccount(), &c.request.Header, status, body)
-	err := c.Push(c.session.GetGateID(), []string{c.session.GetChannelID()}, packet)
+	gateway, channels := c.session.GetGateID(), []string{c.session.GetChannelID()}
+	// 服务间调用的响应直接返回给发起方
+	if from, ok := c.request.GetString(wire.MetaCallFrom); ok {
+		gateway, channels = from, []string{c.request.ChannelID}
+		packet.AddStringMeta(wire.MetaCallFrom, from)
+	}
+	err := c.Push(gateway, channels, packet)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (c *ContextImpl) RespStatus() pkt.Status {
+	return c.status
+}
+
 // Dispatch the packet to the Destination of request,
 // the header flag of this packet will be set with FlagDelivery
 // exceptMe:  exclude self if self is false
@@ -136,9 +168,31 @@ func (c *ContextImpl) Dispatch(body proto.Message, recvs ...*Location) error {
 	return nil
 }
 
+// Request the logic service of command, the response is matched by Sequence.
+// A default timeout is applied if ctx has no deadline
+func (c *ContextImpl) Request(ctx context.Context, command string, body proto.Message, opts ...pkt.HeaderOption) (*pkt.LogicPkt, error) {
+	requester, ok := c.Dispatcher.(Requester)
+	if !ok {
+		return nil, ErrRequestNotSupported
+	}
+	opts = append([]pkt.HeaderOption{pkt.WithChannel(c.request.ChannelID)}, opts...)
+	packet := pkt.New(command, opts...)
+	packet.WriteBody(body)
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, constants.DefaultRequestWait)
+		defer cancel()
+	}
+	trace.Inject(ctx, packet)
+	logger.Debugf("--> Request %s from %s", &packet.Header, c.Session().GetAccount())
+	return requester.Request(ctx, packet.ServiceName(), packet)
+}
+
 func (c *ContextImpl) reset() {
 	c.request = nil
 	c.index = 0
 	c.handlers = nil
 	c.session = nil
+	c.status = pkt.Status_Success
+	c.ctx = nil
 }
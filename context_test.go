@@ -0,0 +1,82 @@
+package cim
+
+import (
+	"context"
+	"testing"
+
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"github.com/stretchr/testify/assert"
+)
+
+type pushed struct {
+	gateway  string
+	channels []string
+	packet   *pkt.LogicPkt
+}
+
+type testDispatcher struct {
+	pushes   []pushed
+	requests []*pkt.LogicPkt
+}
+
+func (d *testDispatcher) Push(gateway string, channels []string, p *pkt.LogicPkt) error {
+	d.pushes = append(d.pushes, pushed{gateway: gateway, channels: channels, packet: p})
+	return nil
+}
+
+func (d *testDispatcher) Request(ctx context.Context, serviceName string, p *pkt.LogicPkt) (*pkt.LogicPkt, error) {
+	d.requests = append(d.requests, p)
+	resp := pkt.NewFrom(&p.Header)
+	resp.Flag = pkt.Flag_Response
+	resp.WriteBody(&pkt.ErrorResponse{Message: serviceName})
+	return resp, nil
+}
+
+type testStorage struct{}
+
+func (s *testStorage) Add(session *pkt.Session) error                      { return nil }
+func (s *testStorage) Delete(account string, channelID string) error       { return nil }
+func (s *testStorage) Get(channelID string) (*pkt.Session, error)          { return nil, ErrSessionNil }
+func (s *testStorage) GetLocations(account ...string) ([]*Location, error) { return nil, ErrSessionNil }
+func (s *testStorage) GetLocation(account string, device string) (*Location, error) {
+	return nil, ErrSessionNil
+}
+
+func TestContextRequest(t *testing.T) {
+	r := NewRouter()
+	var got pkt.ErrorResponse
+	r.Handle(wire.CommandChatUserTalk, func(ctx Context) {
+		resp, err := ctx.Request(context.Background(), "presence.query", &pkt.MessageAckRequest{MessageID: 1})
+		assert.Nil(t, err)
+		assert.Nil(t, resp.ReadBody(&got))
+	})
+	dispatcher := &testDispatcher{}
+	session := &pkt.Session{ChannelID: "ch1", GateID: "gateway01", Account: "test1"}
+	err := r.Serve(pkt.New(wire.CommandChatUserTalk, pkt.WithChannel("ch1")), dispatcher, &testStorage{}, session)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(dispatcher.requests))
+	assert.Equal(t, "ch1", dispatcher.requests[0].ChannelID)
+	assert.Equal(t, "presence.query", dispatcher.requests[0].Command)
+	assert.Equal(t, "presence", got.Message)
+}
+
+func TestContextRespToCaller(t *testing.T) {
+	r := NewRouter()
+	r.Handle("presence.query", func(ctx Context) {
+		_ = ctx.Resp(pkt.Status_Success, nil)
+	})
+	dispatcher := &testDispatcher{}
+	session := &pkt.Session{ChannelID: "ch1", GateID: "gateway01", Account: "test1"}
+
+	packet := pkt.New("presence.query", pkt.WithChannel("ch1"))
+	packet.AddStringMeta(wire.MetaCallFrom, "chat01")
+	err := r.Serve(packet, dispatcher, &testStorage{}, session)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(dispatcher.pushes))
+	assert.Equal(t, "chat01", dispatcher.pushes[0].gateway)
+	assert.Equal(t, []string{"ch1"}, dispatcher.pushes[0].channels)
+	assert.Equal(t, packet.Sequence, dispatcher.pushes[0].packet.Sequence)
+}
@@ -58,6 +58,8 @@ type DefaultServer struct {
 	once    sync.Once
 	options *ServerOptions
 	quit    int32
+	lock    sync.Mutex
+	lst     net.Listener
 }
 
 // NewServer NewServer
@@ -103,6 +105,9 @@ func (s *DefaultServer) Start() error {
 	if err != nil {
 		return err
 	}
+	s.lock.Lock()
+	s.lst = lst
+	s.lock.Unlock()
 	// 采用协程池来增加复用
 	mgpool, _ := ants.NewPool(s.options.MessageGPool, ants.WithPreAlloc(true))
 	defer func() {
@@ -116,6 +121,9 @@ func (s *DefaultServer) Start() error {
 			if rawconn != nil {
 				rawconn.Close()
 			}
+			if atomic.LoadInt32(&s.quit) == 1 {
+				break
+			}
 			log.Warn(err)
 			continue
 		}
@@ -158,7 +166,7 @@ func (s *DefaultServer) connHandler(rawconn net.Conn, gpool *ants.Pool) {
 	if meta == nil {
 		meta = Meta{}
 	}
-	channel := NewChannel(id, meta, conn, gpool)
+	channel := newChannel(id, meta, conn, gpool)
 	channel.SetReadWait(s.options.Readwait)
 	channel.SetWriteWait(s.options.Writewait)
 	s.Add(channel)
@@ -175,6 +183,8 @@ func (s *DefaultServer) connHandler(rawconn net.Conn, gpool *ants.Pool) {
 	s.Remove(channel.ID())
 	_ = s.DisConnect(channel.ID())
 	channel.Close()
+	// 写循环还在使用wr，等它退出之后才能归还缓冲区
+	channel.wait()
 }
 
 // ShutDown Shutdown
@@ -187,9 +197,15 @@ func (s *DefaultServer) ShutDown(ctx context.Context) error {
 		defer func() {
 			log.Infoln("shutdown")
 		}()
-		if atomic.CompareAndSwapInt32(&s.quit, 0, 1) {
+		if !atomic.CompareAndSwapInt32(&s.quit, 0, 1) {
 			return
 		}
+		// 停止接收新的连接
+		s.lock.Lock()
+		if s.lst != nil {
+			_ = s.lst.Close()
+		}
+		s.lock.Unlock()
 
 		// close channels
 		chanels := s.ChannelMap.All()
@@ -34,10 +34,11 @@ func usertalk(wsurl, appSecret string, threads, count int, online bool) error {
 
 	if online {
 		cli2, _ := dialer.Login(wsurl, "test1")
+		reader := dialer.NewReader(cli2)
 
 		go func() {
 			for {
-				_, err := cli2.Read()
+				_, err := reader.Read()
 				if err != nil {
 					return
 				}
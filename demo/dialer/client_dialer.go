@@ -34,13 +34,15 @@ func (d *ClientDialer) DialAndHandshake(ctx cim.DialerContext) (net.Conn, error)
 		Account: ctx.Id,
 		App:     "cim",
 		Exp:     time.Now().AddDate(0, 0, 1).Unix(),
+		Iat:     time.Now().Unix(),
 	})
 	if err != nil {
 		return nil, err
 	}
 	// 3. 发送一条CommandLoginSignIn消息
 	loginreq := pkt.New(wire.CommandLoginSignIn).WriteBody(&pkt.LoginRequest{
-		Token: tk,
+		Token:        tk,
+		Compressions: pkt.Compressions,
 	})
 	err = wsutil.WriteClientBinary(conn, pkt.Marshal(loginreq))
 	if err != nil {
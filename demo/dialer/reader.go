@@ -0,0 +1,47 @@
+package dialer
+
+import (
+	"bytes"
+
+	cim "cirno-im"
+	"cirno-im/logger"
+	"cirno-im/wire/command/client"
+	"cirno-im/wire/pkt"
+)
+
+// Reader 读取登录之后客户端收到的packet，推送出现缺口时请求网关重发，并丢弃重复收到的推送
+type Reader struct {
+	cli    cim.Client
+	pushes *client.PushReceiver
+}
+
+func NewReader(cli cim.Client) *Reader {
+	return &Reader{
+		cli:    cli,
+		pushes: client.NewPushReceiver(cli),
+	}
+}
+
+// Read 返回下一个需要处理的packet，跳过非二进制的帧
+func (r *Reader) Read() (*pkt.LogicPkt, error) {
+	for {
+		frame, err := r.cli.Read()
+		if err != nil {
+			return nil, err
+		}
+		if frame.GetOpCode() != cim.OpBinary {
+			continue
+		}
+		packet, err := pkt.MustReadLogicPkt(bytes.NewBuffer(frame.GetPayload()))
+		if err != nil {
+			return nil, err
+		}
+		ok, err := r.pushes.Receive(packet)
+		if err != nil {
+			logger.WithField("id", r.cli.ID()).Warn(err)
+		}
+		if ok {
+			return packet, nil
+		}
+	}
+}
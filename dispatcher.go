@@ -1,7 +1,15 @@
 package cim
 
-import "cirno-im/wire/pkt"
+import (
+	"cirno-im/wire/pkt"
+	"context"
+)
 
 type Dispatcher interface {
 	Push(gateway string, channels []string, p *pkt.LogicPkt) error
 }
+
+// Requester 逻辑服务之间的请求/响应调用，响应通过Sequence与请求匹配
+type Requester interface {
+	Request(ctx context.Context, serviceName string, p *pkt.LogicPkt) (*pkt.LogicPkt, error)
+}
@@ -110,6 +110,11 @@ func SetLevel(level string) error {
 	return err
 }
 
+// GetLevel GetLevel
+func GetLevel() string {
+	return std.GetLevel().String()
+}
+
 // Entry Entry
 type Entry *logrus.Entry
 
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"time"
+
+	"cirno-im"
+	"cirno-im/logger"
+)
+
+// AccessLog 记录每个指令的访问日志及处理耗时
+func AccessLog() cim.HandlerFunc {
+	return func(ctx cim.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		logger.WithFields(logger.Fields{
+			"module":    "accesslog",
+			"Command":   ctx.Header().Command,
+			"ChannelId": ctx.Header().ChannelID,
+			"Seq":       ctx.Header().Sequence,
+			"Account":   ctx.Session().GetAccount(),
+			"Status":    ctx.RespStatus().String(),
+			"Latency":   time.Since(start).String(),
+		}).Info()
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"cirno-im"
+	"cirno-im/constants"
+	"cirno-im/wire/pkt"
+)
+
+// Auth 校验会话中的app及account。
+// 网关注入到Meta中的app和account必须与会话一致，apps不为空时只允许其中的app访问
+func Auth(apps ...string) cim.HandlerFunc {
+	allowed := make(map[string]struct{}, len(apps))
+	for _, app := range apps {
+		allowed[app] = struct{}{}
+	}
+	return func(ctx cim.Context) {
+		session := ctx.Session()
+		if session.GetAccount() == "" || session.GetApp() == "" {
+			_ = ctx.Resp(pkt.Status_Unauthorized, &pkt.ErrorResponse{Message: "Unauthorized"})
+			return
+		}
+		if app, ok := pkt.FindString(ctx.Header().Meta, constants.MetaKeyApp); ok && app != session.GetApp() {
+			_ = ctx.Resp(pkt.Status_Unauthorized, &pkt.ErrorResponse{Message: "app mismatch"})
+			return
+		}
+		if account, ok := pkt.FindString(ctx.Header().Meta, constants.MetaKeyAccount); ok && account != session.GetAccount() {
+			_ = ctx.Resp(pkt.Status_Unauthorized, &pkt.ErrorResponse{Message: "account mismatch"})
+			return
+		}
+		if len(allowed) > 0 {
+			if _, ok := allowed[session.GetApp()]; !ok {
+				_ = ctx.Resp(pkt.Status_Forbidden, &pkt.ErrorResponse{Message: "Forbidden"})
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
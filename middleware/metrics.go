@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"time"
+
+	"cirno-im"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var handlerDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "cim",
+	Name:      "handler_duration_seconds",
+	Help:      "逻辑服务指令处理耗时",
+	Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+}, []string{"command", "status"})
+
+// Metrics 按指令及响应状态统计处理耗时
+func Metrics() cim.HandlerFunc {
+	return func(ctx cim.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		handlerDurationSeconds.WithLabelValues(ctx.Header().Command, ctx.RespStatus().String()).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"testing"
+
+	"cirno-im"
+	"cirno-im/constants"
+	"cirno-im/wire"
+	"cirno-im/wire/command"
+	"cirno-im/wire/pkt"
+	"github.com/stretchr/testify/assert"
+)
+
+type respDispatcher struct {
+	resps []*pkt.LogicPkt
+}
+
+func (d *respDispatcher) Push(gateway string, channels []string, p *pkt.LogicPkt) error {
+	d.resps = append(d.resps, p)
+	return nil
+}
+
+type nopStorage struct{}
+
+func (s *nopStorage) Add(session *pkt.Session) error                { return nil }
+func (s *nopStorage) Delete(account string, channelID string) error { return nil }
+func (s *nopStorage) Get(channelID string) (*pkt.Session, error)    { return nil, cim.ErrSessionNil }
+func (s *nopStorage) GetLocations(account ...string) ([]*cim.Location, error) {
+	return nil, cim.ErrSessionNil
+}
+func (s *nopStorage) GetLocation(account string, device string) (*cim.Location, error) {
+	return nil, cim.ErrSessionNil
+}
+
+func serve(r *cim.Router, packet *pkt.LogicPkt, session *pkt.Session) *respDispatcher {
+	dispatcher := &respDispatcher{}
+	_ = r.Serve(packet, dispatcher, &nopStorage{}, session)
+	return dispatcher
+}
+
+func ok(ctx cim.Context) {
+	_ = ctx.Resp(pkt.Status_Success, nil)
+}
+
+func TestRateLimit(t *testing.T) {
+	r := cim.NewRouter()
+	r.Handle(wire.CommandChatUserTalk, RateLimit(1, 2), ok)
+
+	session := &pkt.Session{ChannelID: "ch1", GateID: "gateway01", Account: "test1", App: "cim"}
+	for i := 0; i < 2; i++ {
+		d := serve(r, pkt.New(wire.CommandChatUserTalk), session)
+		assert.Equal(t, pkt.Status_Success, d.resps[0].Status)
+	}
+	d := serve(r, pkt.New(wire.CommandChatUserTalk), session)
+	assert.Equal(t, pkt.Status_TooManyRequests, d.resps[0].Status)
+
+	// other accounts are not affected
+	d = serve(r, pkt.New(wire.CommandChatUserTalk), &pkt.Session{ChannelID: "ch2", Account: "test2", App: "cim"})
+	assert.Equal(t, pkt.Status_Success, d.resps[0].Status)
+}
+
+func TestAuth(t *testing.T) {
+	r := cim.NewRouter()
+	r.Handle(wire.CommandChatUserTalk, Auth("cim"), ok)
+
+	d := serve(r, pkt.New(wire.CommandChatUserTalk), &pkt.Session{ChannelID: "ch1"})
+	assert.Equal(t, pkt.Status_Unauthorized, d.resps[0].Status)
+
+	d = serve(r, pkt.New(wire.CommandChatUserTalk), &pkt.Session{ChannelID: "ch1", Account: "test1", App: "other"})
+	assert.Equal(t, pkt.Status_Forbidden, d.resps[0].Status)
+
+	packet := pkt.New(wire.CommandChatUserTalk)
+	packet.AddStringMeta(constants.MetaKeyApp, "cim")
+	packet.AddStringMeta(constants.MetaKeyAccount, "test2")
+	d = serve(r, packet, &pkt.Session{ChannelID: "ch1", Account: "test1", App: "cim"})
+	assert.Equal(t, pkt.Status_Unauthorized, d.resps[0].Status)
+
+	packet = pkt.New(wire.CommandChatUserTalk)
+	packet.AddStringMeta(constants.MetaKeyApp, "cim")
+	packet.AddStringMeta(constants.MetaKeyAccount, "test1")
+	d = serve(r, packet, &pkt.Session{ChannelID: "ch1", Account: "test1", App: "cim"})
+	assert.Equal(t, pkt.Status_Success, d.resps[0].Status)
+}
+
+func TestValidate(t *testing.T) {
+	r := cim.NewRouter()
+	r.Use(Validate(command.Default))
+	r.Handle(wire.CommandChatUserTalk, ok)
+	r.Handle("unknown.command", ok)
+	session := &pkt.Session{ChannelID: "ch1", Account: "test1", App: "cim"}
+
+	d := serve(r, pkt.New(wire.CommandChatUserTalk).WriteBody(&pkt.MessageRequest{Type: 1}), session)
+	assert.Equal(t, pkt.Status_InvalidPacketBody, d.resps[0].Status)
+
+	packet := pkt.New(wire.CommandChatUserTalk)
+	packet.Body = []byte("not a json")
+	d = serve(r, packet, session)
+	assert.Equal(t, pkt.Status_InvalidPacketBody, d.resps[0].Status)
+
+	d = serve(r, pkt.New(wire.CommandChatUserTalk).WriteBody(&pkt.MessageRequest{Type: 1, Body: "hello"}), session)
+	assert.Equal(t, pkt.Status_Success, d.resps[0].Status)
+
+	d = serve(r, pkt.New("unknown.command"), session)
+	assert.Equal(t, pkt.Status_Success, d.resps[0].Status)
+}
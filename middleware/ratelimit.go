@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"cirno-im"
+	"cirno-im/logger"
+	"cirno-im/wire/pkt"
+	"golang.org/x/time/rate"
+)
+
+// idleLimiterExpired 限流器空闲超过这个时间就会被清理
+const idleLimiterExpired = time.Minute * 10
+
+type limiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+type accountLimiters struct {
+	sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*limiter
+	cleaned  time.Time
+}
+
+func (l *accountLimiters) allow(key string, now time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+	if now.Sub(l.cleaned) > idleLimiterExpired {
+		for k, v := range l.limiters {
+			if now.Sub(v.lastSeen) > idleLimiterExpired {
+				delete(l.limiters, k)
+			}
+		}
+		l.cleaned = now
+	}
+	lim, ok := l.limiters[key]
+	if !ok {
+		lim = &limiter{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = lim
+	}
+	lim.lastSeen = now
+	return lim.AllowN(now, 1)
+}
+
+// RateLimit 按账号限流，每秒最多limit个请求，允许burst个突发请求。
+// 未登录的会话按ChannelID限流
+func RateLimit(limit float64, burst int) cim.HandlerFunc {
+	limiters := &accountLimiters{
+		limit:    rate.Limit(limit),
+		burst:    burst,
+		limiters: make(map[string]*limiter),
+		cleaned:  time.Now(),
+	}
+	return func(ctx cim.Context) {
+		key := ctx.Session().GetAccount()
+		if key == "" {
+			key = ctx.Header().ChannelID
+		}
+		if !limiters.allow(key, time.Now()) {
+			logger.WithFields(logger.Fields{
+				"module":  "ratelimit",
+				"Command": ctx.Header().Command,
+				"Account": key,
+			}).Warn("too many requests")
+			_ = ctx.Resp(pkt.Status_TooManyRequests, &pkt.ErrorResponse{Message: "TooManyRequests"})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"cirno-im"
+	"cirno-im/wire/command"
+	"cirno-im/wire/pkt"
+)
+
+// Validate 按照registry中登记的请求类型校验包体，没有登记的命令直接放行
+func Validate(registry *command.Registry) cim.HandlerFunc {
+	return func(ctx cim.Context) {
+		schema, ok := registry.Lookup(ctx.Header().Command)
+		if !ok {
+			ctx.Next()
+			return
+		}
+		if req := schema.NewRequest(); req != nil {
+			if err := ctx.ReadBody(req); err != nil {
+				_ = ctx.RespWithError(pkt.Status_InvalidPacketBody, err)
+				return
+			}
+			if err := schema.Check(req); err != nil {
+				_ = ctx.RespWithError(pkt.Status_InvalidPacketBody, err)
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,158 @@
+// Package consultest 提供一个进程内的consul HTTP API替身，只实现Naming用到的接口，用于测试
+package consultest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// maxWait 阻塞查询最多等待的时间
+const maxWait = time.Second * 5
+
+// Server consul的替身，服务注册之后立即出现在catalog中
+type Server struct {
+	*httptest.Server
+	sync.Mutex
+	index    uint64
+	services map[string]*api.AgentServiceRegistration
+	status   map[string]string
+	changed  chan struct{}
+	quit     chan struct{}
+	once     sync.Once
+}
+
+// NewServer 启动一个替身，使用完之后需要Close
+func NewServer() *Server {
+	s := &Server{
+		index:    1,
+		services: make(map[string]*api.AgentServiceRegistration),
+		status:   make(map[string]string),
+		changed:  make(chan struct{}),
+		quit:     make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /v1/agent/service/register", s.register)
+	mux.HandleFunc("PUT /v1/agent/service/deregister/{id}", s.deregister)
+	mux.HandleFunc("GET /v1/catalog/service/{name}", s.catalog)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Close 结束阻塞中的查询并关闭服务
+func (s *Server) Close() {
+	s.once.Do(func() {
+		close(s.quit)
+		s.Server.Close()
+	})
+}
+
+// SetStatus 修改服务健康检查的状态，如api.HealthCritical
+func (s *Server) SetStatus(id, status string) {
+	s.Lock()
+	defer s.Unlock()
+	s.status[id] = status
+	s.bump()
+}
+
+// bump 数据变化之后增加index并唤醒阻塞的查询，调用方需要持有锁
+func (s *Server) bump() {
+	s.index++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	var reg api.AgentServiceRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.services[reg.ID] = &reg
+	s.bump()
+}
+
+func (s *Server) deregister(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.services, r.PathValue("id"))
+	s.bump()
+}
+
+func (s *Server) catalog(w http.ResponseWriter, r *http.Request) {
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	s.Lock()
+	if index >= s.index {
+		changed := s.changed
+		s.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(maxWait):
+		case <-s.quit:
+			http.Error(w, "server closed", http.StatusServiceUnavailable)
+			return
+		case <-r.Context().Done():
+			return
+		}
+		s.Lock()
+	}
+	defer s.Unlock()
+
+	name := r.PathValue("name")
+	tags := r.URL.Query()["tag"]
+	ids := make([]string, 0, len(s.services))
+	for id := range s.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	list := make([]*api.CatalogService, 0)
+	for _, id := range ids {
+		reg := s.services[id]
+		if reg.Name != name || !hasTags(reg.Tags, tags) {
+			continue
+		}
+		status := api.HealthPassing
+		if st, ok := s.status[id]; ok {
+			status = st
+		}
+		list = append(list, &api.CatalogService{
+			ServiceID:      reg.ID,
+			ServiceName:    reg.Name,
+			ServiceAddress: reg.Address,
+			ServicePort:    reg.Port,
+			ServiceTags:    reg.Tags,
+			ServiceMeta:    reg.Meta,
+			Checks:         api.HealthChecks{{ServiceID: reg.ID, Status: status}},
+		})
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+	w.Header().Set("X-Consul-LastContact", "0")
+	w.Header().Set("X-Consul-KnownLeader", "true")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func hasTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if strings.EqualFold(h, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -15,13 +15,21 @@ import (
 const (
 	KeyProtocol  = "protocol"
 	KeyHealthURL = "health_url"
+	KeyNamespace = "namespace"
 )
 
+// NamespaceTag 注册时给服务打上的命名空间标签
+func NamespaceTag(namespace string) string {
+	return KeyNamespace + ":" + namespace
+}
+
 type Watch struct {
 	Service   string
-	Callback  func([]cim.ServiceRegistration)
+	Callback  func([]naming.Event)
 	WaitIndex uint64
 	Quit      chan struct{}
+	// Last 上一次回调时的服务列表，用来计算变化
+	Last []cim.ServiceRegistration
 }
 
 type Naming struct {
@@ -72,13 +80,14 @@ func (n *Naming) load(name string, waitIndex uint64, tags ...string) ([]cim.Serv
 			continue
 		}
 		services = append(services, &naming.DefaultService{
-			Id:       s.ServiceID,
-			Name:     s.ServiceName,
-			Address:  s.ServiceAddress,
-			Port:     s.ServicePort,
-			Protocol: s.ServiceMeta[KeyProtocol],
-			Tags:     s.ServiceTags,
-			Meta:     s.ServiceMeta,
+			Id:        s.ServiceID,
+			Name:      s.ServiceName,
+			Address:   s.ServiceAddress,
+			Port:      s.ServicePort,
+			Protocol:  s.ServiceMeta[KeyProtocol],
+			Namespace: s.ServiceMeta[KeyNamespace],
+			Tags:      s.ServiceTags,
+			Meta:      s.ServiceMeta,
 		})
 	}
 	logger.Debugf("load service: %v, meta:%v", services, meta)
@@ -92,12 +101,17 @@ func (n *Naming) Register(s cim.ServiceRegistration) error {
 		Address: s.PublicAddress(),
 		Port:    s.PublicPort(),
 		Tags:    s.GetTags(),
-		Meta:    s.GetMetadata(),
+		Meta:    make(map[string]string, len(s.GetMetadata())+2),
 	}
-	if reg.Meta == nil {
-		reg.Meta = make(map[string]string)
+	for k, v := range s.GetMetadata() {
+		reg.Meta[k] = v
 	}
 	reg.Meta[KeyProtocol] = s.GetProtocol()
+	// consul的开源版本没有命名空间，使用标签和meta区分
+	if ns := s.GetNamespace(); ns != "" {
+		reg.Meta[KeyNamespace] = ns
+		reg.Tags = append(append([]string{}, reg.Tags...), NamespaceTag(ns))
+	}
 	// consul健康检查
 	healthURL := s.GetMetadata()[KeyHealthURL]
 	if healthURL != "" {
@@ -118,7 +132,7 @@ func (n *Naming) Deregister(serviceID string) error {
 	return n.cli.Agent().ServiceDeregister(serviceID)
 }
 
-func (n *Naming) Subscribe(serviceName string, callback func([]cim.ServiceRegistration)) error {
+func (n *Naming) Subscribe(serviceName string, callback func([]naming.Event)) error {
 	n.Lock()
 	defer n.Unlock()
 	if _, ok := n.watches[serviceName]; ok {
@@ -150,10 +164,16 @@ func (n *Naming) Unsubscribe(serviceName string) error {
 func (n *Naming) watch(wh *Watch) {
 	stopped := false
 
-	var doWatch = func(service string, callback func([]cim.ServiceRegistration)) {
+	var doWatch = func(service string, callback func([]naming.Event)) {
 		services, meta, err := n.load(service, wh.WaitIndex) // <-- blocking until services has changed
 		if err != nil {
 			logger.Warn(err)
+			// 避免consul不可用时不停地重试
+			select {
+			case <-wh.Quit:
+				stopped = true
+			case <-time.After(time.Second):
+			}
 			return
 		}
 		select {
@@ -165,8 +185,10 @@ func (n *Naming) watch(wh *Watch) {
 		}
 
 		wh.WaitIndex = meta.LastIndex
-		if callback != nil {
-			callback(services)
+		events := naming.Diff(wh.Last, services)
+		wh.Last = services
+		if callback != nil && len(events) > 0 {
+			callback(events)
 		}
 	}
 
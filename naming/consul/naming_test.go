@@ -0,0 +1,61 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"cirno-im/naming"
+	"cirno-im/naming/consul/consultest"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func waitEvents(t *testing.T, ch chan []naming.Event) []naming.Event {
+	select {
+	case events := <-ch:
+		return events
+	case <-time.After(time.Second * 3):
+		t.Fatal("wait events timeout")
+	}
+	return nil
+}
+
+func TestSubscribe(t *testing.T) {
+	srv := consultest.NewServer()
+	defer srv.Close()
+
+	ns, err := NewNaming(srv.URL)
+	assert.Nil(t, err)
+	chat01 := &naming.DefaultService{Id: "chat01", Name: "chat", Address: "127.0.0.1", Port: 8001, Protocol: "tcp"}
+	assert.Nil(t, ns.Register(chat01))
+
+	got := make(chan []naming.Event, 10)
+	assert.Nil(t, ns.Subscribe("chat", func(events []naming.Event) { got <- events }))
+	defer ns.Unsubscribe("chat")
+	// 等待订阅完成第一次加载
+	time.Sleep(time.Millisecond * 100)
+
+	_ = ns.Register(&naming.DefaultService{Id: "chat02", Name: "chat", Address: "127.0.0.1", Port: 8002, Protocol: "tcp"})
+	events := waitEvents(t, got)
+	assert.Len(t, events, 1)
+	assert.Equal(t, naming.EventAdded, events[0].Type)
+	assert.Equal(t, "chat02", events[0].Service.ServiceID())
+
+	chat01.Meta = map[string]string{"zone": "zone_ali_01"}
+	_ = ns.Register(chat01)
+	events = waitEvents(t, got)
+	assert.Len(t, events, 1)
+	assert.Equal(t, naming.EventUpdated, events[0].Type)
+	assert.Equal(t, "zone_ali_01", events[0].Service.GetMetadata()["zone"])
+
+	// 健康检查失败的服务视为删除
+	srv.SetStatus("chat02", api.HealthCritical)
+	events = waitEvents(t, got)
+	assert.Equal(t, naming.EventRemoved, events[0].Type)
+	assert.Equal(t, "chat02", events[0].Service.ServiceID())
+
+	_ = ns.Deregister("chat01")
+	events = waitEvents(t, got)
+	assert.Equal(t, naming.EventRemoved, events[0].Type)
+	assert.Equal(t, "chat01", events[0].Service.ServiceID())
+}
@@ -0,0 +1,103 @@
+package naming
+
+import (
+	"reflect"
+	"sort"
+
+	"cirno-im"
+)
+
+// EventType 服务变化的类型
+type EventType int
+
+const (
+	EventAdded EventType = iota + 1
+	EventRemoved
+	EventUpdated
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAdded:
+		return "added"
+	case EventRemoved:
+		return "removed"
+	case EventUpdated:
+		return "updated"
+	}
+	return "unknown"
+}
+
+// Event 订阅的服务发生的一个变化，Removed事件中的Service是变化前的注册信息
+type Event struct {
+	Type    EventType
+	Service cim.ServiceRegistration
+}
+
+// Diff 比较同一个服务前后两次的列表，返回按照删除、更新、新增排列的事件
+func Diff(old, new []cim.ServiceRegistration) []Event {
+	olds := make(map[string]cim.ServiceRegistration, len(old))
+	for _, s := range old {
+		olds[s.ServiceID()] = s
+	}
+	news := make(map[string]cim.ServiceRegistration, len(new))
+	for _, s := range new {
+		news[s.ServiceID()] = s
+	}
+
+	events := make([]Event, 0)
+	for _, id := range sortedKeys(olds) {
+		if _, ok := news[id]; !ok {
+			events = append(events, Event{Type: EventRemoved, Service: olds[id]})
+		}
+	}
+	for _, id := range sortedKeys(news) {
+		if s, ok := olds[id]; ok && !equal(s, news[id]) {
+			events = append(events, Event{Type: EventUpdated, Service: news[id]})
+		}
+	}
+	for _, id := range sortedKeys(news) {
+		if _, ok := olds[id]; !ok {
+			events = append(events, Event{Type: EventAdded, Service: news[id]})
+		}
+	}
+	return events
+}
+
+func sortedKeys(m map[string]cim.ServiceRegistration) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func equal(a, b cim.ServiceRegistration) bool {
+	return a.ServiceName() == b.ServiceName() &&
+		a.GetNamespace() == b.GetNamespace() &&
+		a.PublicAddress() == b.PublicAddress() &&
+		a.PublicPort() == b.PublicPort() &&
+		a.GetProtocol() == b.GetProtocol() &&
+		equalStrings(a.GetTags(), b.GetTags()) &&
+		equalMeta(a.GetMetadata(), b.GetMetadata())
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return len(a) == 0 || reflect.DeepEqual(a, b)
+}
+
+func equalMeta(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package naming
+
+import (
+	"testing"
+
+	"cirno-im"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	old := []cim.ServiceRegistration{
+		NewEntry("s1", "chat", "tcp", "127.0.0.1", 8001),
+		NewEntry("s2", "chat", "tcp", "127.0.0.1", 8002),
+		&DefaultService{Id: "s3", Name: "chat", Protocol: "tcp", Address: "127.0.0.1", Port: 8003, Meta: map[string]string{}},
+	}
+	new := []cim.ServiceRegistration{
+		NewEntry("s4", "chat", "tcp", "127.0.0.1", 8004),
+		NewEntry("s2", "chat", "tcp", "127.0.0.1", 9002),
+		// nil和空的meta是一样的
+		NewEntry("s3", "chat", "tcp", "127.0.0.1", 8003),
+	}
+	events := Diff(old, new)
+	assert.Len(t, events, 3)
+	assert.Equal(t, EventRemoved, events[0].Type)
+	assert.Equal(t, "s1", events[0].Service.ServiceID())
+	assert.Equal(t, EventUpdated, events[1].Type)
+	assert.Equal(t, 9002, events[1].Service.PublicPort())
+	assert.Equal(t, EventAdded, events[2].Type)
+	assert.Equal(t, "s4", events[2].Service.ServiceID())
+
+	assert.Len(t, Diff(new, new), 0)
+}
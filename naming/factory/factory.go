@@ -0,0 +1,96 @@
+package factory
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"cirno-im/naming"
+	"cirno-im/naming/consul"
+	"cirno-im/naming/gossip"
+	"cirno-im/naming/static"
+	"github.com/spf13/pflag"
+)
+
+// 注册中心的类型
+const (
+	KindConsul = "consul"
+	KindStatic = "static"
+	KindGossip = "gossip"
+)
+
+// DefaultFile 静态注册中心的默认文件
+const DefaultFile = "./naming.yaml"
+
+// Options 创建注册中心的参数，一般由命令行指定
+type Options struct {
+	Kind string
+	// File 静态注册中心的服务文件
+	File string
+	// GossipBind gossip监听的地址 host:port
+	GossipBind string
+	// GossipAdvertise 其它节点连接本节点使用的地址 host:port，为空时自动选择
+	GossipAdvertise string
+	// GossipSeeds 加入集群使用的种子节点
+	GossipSeeds []string
+}
+
+// AddFlags 把注册中心的参数添加到命令行
+func AddFlags(flags *pflag.FlagSet, opts *Options) {
+	flags.StringVar(&opts.Kind, "naming", KindConsul, "naming of consul, static or gossip")
+	flags.StringVar(&opts.File, "naming-file", DefaultFile, "services file of static naming")
+	flags.StringVar(&opts.GossipBind, "gossip-bind", fmt.Sprintf(":%d", gossip.DefaultPort), "bind address of gossip naming")
+	flags.StringVar(&opts.GossipAdvertise, "gossip-advertise", "", "advertise address of gossip naming")
+	flags.StringSliceVar(&opts.GossipSeeds, "gossip-seeds", nil, "seed nodes of gossip naming")
+}
+
+// New 根据类型创建注册中心，Kind为空时使用consul
+func New(opts Options, consulURL string) (naming.Naming, error) {
+	switch opts.Kind {
+	case "", KindConsul:
+		return consul.NewNaming(consulURL)
+	case KindStatic:
+		file := opts.File
+		if file == "" {
+			file = DefaultFile
+		}
+		return static.NewNaming(file)
+	case KindGossip:
+		conf := gossip.Config{Seeds: opts.GossipSeeds}
+		var err error
+		if opts.GossipBind != "" {
+			if conf.BindAddr, conf.BindPort, err = splitHostPort(opts.GossipBind); err != nil {
+				return nil, err
+			}
+		}
+		if opts.GossipAdvertise != "" {
+			if conf.AdvertiseAddr, conf.AdvertisePort, err = splitHostPort(opts.GossipAdvertise); err != nil {
+				return nil, err
+			}
+		}
+		return gossip.NewNaming(conf)
+	default:
+		return nil, fmt.Errorf("unknown naming %q", opts.Kind)
+	}
+}
+
+// Close 关闭需要释放资源的注册中心，如gossip节点会通知其它节点离开
+func Close(ns naming.Naming) error {
+	if closer, ok := ns.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func splitHostPort(addr string) (string, int, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in %s", addr)
+	}
+	return host, p, nil
+}
@@ -0,0 +1,423 @@
+package gossip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"cirno-im"
+	"cirno-im/logger"
+	"cirno-im/naming"
+	"github.com/hashicorp/memberlist"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultPort 默认的gossip端口
+const DefaultPort = 7946
+
+// DefaultLeaveTimeout 退出集群时等待广播离开消息的时间
+const DefaultLeaveTimeout = time.Second * 3
+
+var log = logger.WithFields(logger.Fields{
+	"module": "naming",
+	"pkg":    "gossip",
+})
+
+// Config gossip注册中心的配置
+type Config struct {
+	// Name 节点名，集群内唯一，为空时使用hostname:port
+	Name     string
+	BindAddr string
+	BindPort int
+	// AdvertiseAddr 其它节点连接本节点使用的地址，为空时自动选择
+	AdvertiseAddr string
+	AdvertisePort int
+	// Seeds 加入集群使用的种子节点 host:port，为空时作为第一个节点启动
+	Seeds []string
+	// Local 使用适合本机测试的较短超时
+	Local bool
+}
+
+// nodeState 一个节点上注册的服务，Version只增不减，新的覆盖旧的
+type nodeState struct {
+	Version  int64                    `json:"version"`
+	Services []*naming.DefaultService `json:"services"`
+}
+
+type message struct {
+	Node  string     `json:"node"`
+	State *nodeState `json:"state"`
+}
+
+// Naming 不依赖中心注册服务的Naming：节点通过种子节点加入集群，
+// 成员关系和故障检测由memberlist完成，每个节点注册的服务通过广播和定期的全量同步传播
+type Naming struct {
+	sync.RWMutex
+	name    string
+	list    *memberlist.Memberlist
+	queue   *memberlist.TransmitLimitedQueue
+	writer  io.Closer
+	version int64
+	local   map[string]*naming.DefaultService
+	nodes   map[string]*nodeState
+	alive   map[string]bool
+	watches map[string]*watch
+	changed chan struct{}
+	quit    chan struct{}
+	once    sync.Once
+}
+
+type watch struct {
+	callback func([]naming.Event)
+	last     []cim.ServiceRegistration
+}
+
+// NewNaming 启动本节点并通过Seeds加入集群
+func NewNaming(conf Config) (*Naming, error) {
+	mconf := memberlist.DefaultLANConfig()
+	if conf.Local {
+		mconf = memberlist.DefaultLocalConfig()
+	}
+	if conf.BindAddr != "" {
+		mconf.BindAddr = conf.BindAddr
+	}
+	mconf.BindPort = conf.BindPort
+	if conf.BindPort == 0 {
+		mconf.BindPort = DefaultPort
+	}
+	mconf.AdvertiseAddr = conf.AdvertiseAddr
+	mconf.AdvertisePort = conf.AdvertisePort
+	if conf.AdvertisePort == 0 {
+		mconf.AdvertisePort = mconf.BindPort
+	}
+	mconf.Name = conf.Name
+	if mconf.Name == "" {
+		hostname, _ := os.Hostname()
+		mconf.Name = fmt.Sprintf("%s:%d", hostname, mconf.AdvertisePort)
+	}
+	writer := log.WriterLevel(logrus.DebugLevel)
+	mconf.Logger = stdlog.New(writer, "", 0)
+
+	n := &Naming{
+		name:    mconf.Name,
+		writer:  writer,
+		version: time.Now().UnixNano(),
+		local:   make(map[string]*naming.DefaultService),
+		nodes:   make(map[string]*nodeState),
+		alive:   make(map[string]bool),
+		watches: make(map[string]*watch),
+		changed: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	n.nodes[n.name] = &nodeState{Version: n.version}
+	mconf.Delegate = &delegate{n}
+	mconf.Events = &events{n}
+
+	list, err := memberlist.Create(mconf)
+	if err != nil {
+		_ = writer.Close()
+		return nil, err
+	}
+	n.list = list
+	n.queue = &memberlist.TransmitLimitedQueue{
+		NumNodes:       list.NumMembers,
+		RetransmitMult: mconf.RetransmitMult,
+	}
+	go n.notifyLoop()
+
+	if len(conf.Seeds) > 0 {
+		if _, err := list.Join(conf.Seeds); err != nil {
+			_ = n.Close()
+			return nil, err
+		}
+	}
+	log.Infof("node %s started, members: %d", n.name, list.NumMembers())
+	return n, nil
+}
+
+// Members 当前存活的节点
+func (n *Naming) Members() []string {
+	n.RLock()
+	defer n.RUnlock()
+	members := make([]string, 0, len(n.alive))
+	for name := range n.alive {
+		members = append(members, name)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Close 广播离开消息并关闭本节点
+func (n *Naming) Close() error {
+	var err error
+	n.once.Do(func() {
+		close(n.quit)
+		if err = n.list.Leave(DefaultLeaveTimeout); err != nil {
+			log.Warn(err)
+		}
+		err = n.list.Shutdown()
+		_ = n.writer.Close()
+	})
+	return err
+}
+
+func (n *Naming) Find(name string, tags ...string) ([]cim.ServiceRegistration, error) {
+	n.RLock()
+	defer n.RUnlock()
+	return n.find(name, tags...), nil
+}
+
+func (n *Naming) find(name string, tags ...string) []cim.ServiceRegistration {
+	nodes := make([]string, 0, len(n.nodes))
+	for node := range n.nodes {
+		if n.alive[node] {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+
+	services := make([]cim.ServiceRegistration, 0)
+	for _, node := range nodes {
+		for _, s := range n.nodes[node].Services {
+			if s.Name == name && hasTags(s.Tags, tags) {
+				services = append(services, s)
+			}
+		}
+	}
+	return services
+}
+
+func hasTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Naming) Register(s cim.ServiceRegistration) error {
+	if s.ServiceID() == "" {
+		return errors.New("service id is required")
+	}
+	meta := make(map[string]string, len(s.GetMetadata()))
+	for k, v := range s.GetMetadata() {
+		meta[k] = v
+	}
+	n.Lock()
+	n.local[s.ServiceID()] = &naming.DefaultService{
+		Id:        s.ServiceID(),
+		Name:      s.ServiceName(),
+		Namespace: s.GetNamespace(),
+		Address:   s.PublicAddress(),
+		Port:      s.PublicPort(),
+		Protocol:  s.GetProtocol(),
+		Tags:      s.GetTags(),
+		Meta:      meta,
+	}
+	n.publish()
+	n.Unlock()
+	return nil
+}
+
+func (n *Naming) Deregister(serviceID string) error {
+	n.Lock()
+	delete(n.local, serviceID)
+	n.publish()
+	n.Unlock()
+	return nil
+}
+
+// publish 更新本节点的状态并广播，调用方需要持有写锁
+func (n *Naming) publish() {
+	ids := make([]string, 0, len(n.local))
+	for id := range n.local {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	services := make([]*naming.DefaultService, 0, len(ids))
+	for _, id := range ids {
+		services = append(services, n.local[id])
+	}
+	n.version++
+	state := &nodeState{Version: n.version, Services: services}
+	n.nodes[n.name] = state
+
+	// 超过UDP包大小的状态不会被广播出去，依靠定期的全量同步传播
+	msg, _ := json.Marshal(&message{Node: n.name, State: state})
+	n.queue.QueueBroadcast(&broadcast{node: n.name, msg: msg})
+	n.trigger()
+}
+
+// merge 合并其它节点的状态，返回是否有变化，调用方需要持有写锁
+func (n *Naming) merge(node string, state *nodeState) bool {
+	if node == n.name || state == nil {
+		return false
+	}
+	if cur, ok := n.nodes[node]; ok && cur.Version >= state.Version {
+		return false
+	}
+	n.nodes[node] = state
+	return true
+}
+
+func (n *Naming) trigger() {
+	select {
+	case n.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Naming) Subscribe(serviceName string, callback func([]naming.Event)) error {
+	n.Lock()
+	defer n.Unlock()
+	if _, ok := n.watches[serviceName]; ok {
+		return errors.New("serviceName has already been registered")
+	}
+	n.watches[serviceName] = &watch{
+		callback: callback,
+		last:     n.find(serviceName),
+	}
+	return nil
+}
+
+func (n *Naming) Unsubscribe(serviceName string) error {
+	n.Lock()
+	defer n.Unlock()
+	delete(n.watches, serviceName)
+	return nil
+}
+
+// notifyLoop 在memberlist的回调之外通知订阅者，避免在回调中阻塞gossip
+func (n *Naming) notifyLoop() {
+	for {
+		select {
+		case <-n.quit:
+			return
+		case <-n.changed:
+		}
+		type notify struct {
+			callback func([]naming.Event)
+			events   []naming.Event
+		}
+		var notifies []notify
+		n.Lock()
+		for name, w := range n.watches {
+			services := n.find(name)
+			events := naming.Diff(w.last, services)
+			w.last = services
+			if len(events) > 0 {
+				notifies = append(notifies, notify{w.callback, events})
+			}
+		}
+		n.Unlock()
+		for _, nt := range notifies {
+			nt.callback(nt.events)
+		}
+	}
+}
+
+type broadcast struct {
+	node string
+	msg  []byte
+}
+
+func (b *broadcast) Invalidates(other memberlist.Broadcast) bool {
+	o, ok := other.(*broadcast)
+	return ok && o.node == b.node
+}
+
+func (b *broadcast) Message() []byte { return b.msg }
+
+func (b *broadcast) Finished() {}
+
+// delegate 处理服务状态的广播和全量同步
+type delegate struct {
+	n *Naming
+}
+
+func (d *delegate) NodeMeta(limit int) []byte { return nil }
+
+func (d *delegate) NotifyMsg(buf []byte) {
+	var msg message
+	if err := json.Unmarshal(buf, &msg); err != nil {
+		log.Warn(err)
+		return
+	}
+	d.n.Lock()
+	defer d.n.Unlock()
+	if d.n.merge(msg.Node, msg.State) {
+		// 转发新的状态，让加入较晚、发送方还不知道的节点也能收到
+		d.n.queue.QueueBroadcast(&broadcast{node: msg.Node, msg: append([]byte(nil), buf...)})
+		d.n.trigger()
+	}
+}
+
+func (d *delegate) GetBroadcasts(overhead, limit int) [][]byte {
+	return d.n.queue.GetBroadcasts(overhead, limit)
+}
+
+func (d *delegate) LocalState(join bool) []byte {
+	d.n.RLock()
+	defer d.n.RUnlock()
+	buf, _ := json.Marshal(d.n.nodes)
+	return buf
+}
+
+func (d *delegate) MergeRemoteState(buf []byte, join bool) {
+	var nodes map[string]*nodeState
+	if err := json.Unmarshal(buf, &nodes); err != nil {
+		log.Warn(err)
+		return
+	}
+	d.n.Lock()
+	defer d.n.Unlock()
+	changed := false
+	for node, state := range nodes {
+		if d.n.merge(node, state) {
+			changed = true
+		}
+	}
+	if changed {
+		d.n.trigger()
+	}
+}
+
+// events 维护存活的节点，节点离开或者故障时删除它注册的服务
+type events struct {
+	n *Naming
+}
+
+func (e *events) NotifyJoin(node *memberlist.Node) {
+	log.Infof("node %s(%s) joined", node.Name, node.Address())
+	e.n.Lock()
+	defer e.n.Unlock()
+	e.n.alive[node.Name] = true
+	e.n.trigger()
+}
+
+func (e *events) NotifyLeave(node *memberlist.Node) {
+	log.Infof("node %s(%s) left", node.Name, node.Address())
+	e.n.Lock()
+	defer e.n.Unlock()
+	delete(e.n.alive, node.Name)
+	if node.Name != e.n.name {
+		delete(e.n.nodes, node.Name)
+	}
+	e.n.trigger()
+}
+
+func (e *events) NotifyUpdate(node *memberlist.Node) {}
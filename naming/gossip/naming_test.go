@@ -0,0 +1,94 @@
+package gossip
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"cirno-im"
+	"cirno-im/naming"
+	"github.com/stretchr/testify/assert"
+)
+
+func freePort(t *testing.T) int {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Nil(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func newNode(t *testing.T, name string, seeds ...string) (*Naming, string) {
+	port := freePort(t)
+	n, err := NewNaming(Config{
+		Name:     name,
+		BindAddr: "127.0.0.1",
+		BindPort: port,
+		Seeds:    seeds,
+		Local:    true,
+	})
+	assert.Nil(t, err)
+	return n, fmt.Sprintf("127.0.0.1:%d", port)
+}
+
+func waitServices(t *testing.T, ch chan []cim.ServiceRegistration, count int, timeout time.Duration) []cim.ServiceRegistration {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case s := <-ch:
+			if len(s) == count {
+				return s
+			}
+		case <-deadline:
+			t.Fatalf("wait for %d services timeout", count)
+		}
+	}
+}
+
+func TestGossip(t *testing.T) {
+	n1, seed := newNode(t, "n1")
+	_ = n1.Register(&naming.DefaultService{
+		Id: "chat_1", Name: "chat", Address: "127.0.0.1", Port: 8001, Protocol: "tcp",
+		Meta: map[string]string{"zone": "zone_1"},
+	})
+
+	n2, _ := newNode(t, "n2", seed)
+	n3, _ := newNode(t, "n3", seed)
+	defer n3.Close()
+
+	got := make(chan []cim.ServiceRegistration, 10)
+	assert.Nil(t, n3.Subscribe("chat", func(events []naming.Event) {
+		srvs, _ := n3.Find("chat")
+		got <- srvs
+	}))
+
+	_ = n2.Register(&naming.DefaultService{
+		Id: "chat_2", Name: "chat", Address: "127.0.0.1", Port: 8002, Protocol: "tcp",
+		Meta: map[string]string{"zone": "zone_2"},
+	})
+	srvs := waitServices(t, got, 2, time.Second*5)
+	assert.Equal(t, "chat_1", srvs[0].ServiceID())
+	assert.Equal(t, "zone_2", srvs[1].GetMetadata()["zone"])
+	assert.Equal(t, []string{"n1", "n2", "n3"}, n3.Members())
+
+	// 元数据的变化同样会通知订阅者
+	_ = n1.Register(&naming.DefaultService{
+		Id: "chat_1", Name: "chat", Address: "127.0.0.1", Port: 8001, Protocol: "tcp",
+		Meta: map[string]string{"zone": "zone_1", "state": "adult"},
+	})
+	srvs = waitServices(t, got, 2, time.Second*5)
+	assert.Equal(t, "adult", srvs[0].GetMetadata()["state"])
+
+	// 故障：不发送离开消息直接关闭
+	_ = n1.list.Shutdown()
+	_ = n1.writer.Close()
+	srvs = waitServices(t, got, 1, time.Second*20)
+	assert.Equal(t, "chat_2", srvs[0].ServiceID())
+
+	// 主动离开
+	_ = n2.Close()
+	waitServices(t, got, 0, time.Second*5)
+	found, _ := n3.Find("chat")
+	assert.Len(t, found, 0)
+	assert.Equal(t, []string{"n3"}, n3.Members())
+}
@@ -0,0 +1,82 @@
+package naming
+
+import (
+	"io"
+	"sync"
+
+	"cirno-im"
+)
+
+// namespaceNaming 只发现同一个命名空间中的服务
+type namespaceNaming struct {
+	Naming
+	namespace string
+}
+
+// WithNamespace 包装一个Naming，Find和Subscribe只返回namespace中的服务，
+// 注册不受影响，服务的命名空间由它自己的GetNamespace决定
+func WithNamespace(ns Naming, namespace string) Naming {
+	return &namespaceNaming{Naming: ns, namespace: namespace}
+}
+
+func (n *namespaceNaming) Find(name string, tags ...string) ([]cim.ServiceRegistration, error) {
+	services, err := n.Naming.Find(name, tags...)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]cim.ServiceRegistration, 0, len(services))
+	for _, s := range services {
+		if s.GetNamespace() == n.namespace {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+// Subscribe 服务的命名空间变化时，转换为对应的新增或删除事件
+func (n *namespaceNaming) Subscribe(serviceName string, callback func([]Event)) error {
+	var (
+		lock  sync.Mutex
+		known = make(map[string]bool)
+	)
+	if services, err := n.Find(serviceName); err == nil {
+		for _, s := range services {
+			known[s.ServiceID()] = true
+		}
+	}
+	return n.Naming.Subscribe(serviceName, func(events []Event) {
+		lock.Lock()
+		res := make([]Event, 0, len(events))
+		for _, e := range events {
+			id := e.Service.ServiceID()
+			in := e.Service.GetNamespace() == n.namespace
+			switch {
+			case e.Type == EventRemoved:
+				if known[id] {
+					res = append(res, e)
+				}
+				delete(known, id)
+			case in && known[id]:
+				res = append(res, e)
+			case in:
+				known[id] = true
+				res = append(res, Event{Type: EventAdded, Service: e.Service})
+			case known[id]:
+				delete(known, id)
+				res = append(res, Event{Type: EventRemoved, Service: e.Service})
+			}
+		}
+		lock.Unlock()
+		if len(res) > 0 {
+			callback(res)
+		}
+	})
+}
+
+// Close 关闭被包装的Naming
+func (n *namespaceNaming) Close() error {
+	if closer, ok := n.Naming.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
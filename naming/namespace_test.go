@@ -0,0 +1,56 @@
+package naming
+
+import (
+	"testing"
+
+	"cirno-im"
+	"github.com/stretchr/testify/assert"
+)
+
+// subNaming 只记录订阅回调的Naming
+type subNaming struct {
+	Naming
+	services []cim.ServiceRegistration
+	callback func([]Event)
+}
+
+func (n *subNaming) Find(name string, tags ...string) ([]cim.ServiceRegistration, error) {
+	return n.services, nil
+}
+
+func (n *subNaming) Subscribe(name string, callback func([]Event)) error {
+	n.callback = callback
+	return nil
+}
+
+func TestWithNamespace(t *testing.T) {
+	inner := &subNaming{services: []cim.ServiceRegistration{
+		&DefaultService{Id: "s1", Name: "chat", Namespace: "staging"},
+		&DefaultService{Id: "s2", Name: "chat", Namespace: "loadtest"},
+	}}
+	ns := WithNamespace(inner, "staging")
+	srvs, _ := ns.Find("chat")
+	assert.Len(t, srvs, 1)
+	assert.Equal(t, "s1", srvs[0].ServiceID())
+
+	var got []Event
+	_ = ns.Subscribe("chat", func(events []Event) { got = events })
+	inner.callback([]Event{
+		{Type: EventAdded, Service: &DefaultService{Id: "s3", Namespace: "loadtest"}},
+		{Type: EventAdded, Service: &DefaultService{Id: "s4", Namespace: "staging"}},
+		{Type: EventRemoved, Service: &DefaultService{Id: "s2", Namespace: "loadtest"}},
+	})
+	assert.Len(t, got, 1)
+	assert.Equal(t, "s4", got[0].Service.ServiceID())
+
+	// 命名空间变化
+	inner.callback([]Event{
+		{Type: EventUpdated, Service: &DefaultService{Id: "s1", Namespace: "loadtest"}},
+		{Type: EventUpdated, Service: &DefaultService{Id: "s3", Namespace: "staging"}},
+	})
+	assert.Len(t, got, 2)
+	assert.Equal(t, EventRemoved, got[0].Type)
+	assert.Equal(t, "s1", got[0].Service.ServiceID())
+	assert.Equal(t, EventAdded, got[1].Type)
+	assert.Equal(t, "s3", got[1].Service.ServiceID())
+}
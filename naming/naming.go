@@ -4,7 +4,8 @@ import "cirno-im"
 
 type Naming interface {
 	Find(name string, tags ...string) ([]cim.ServiceRegistration, error)
-	Subscribe(serviceName string, callback func(services []cim.ServiceRegistration)) error
+	// Subscribe 订阅服务的变化，只在有变化时回调，事件由Diff计算
+	Subscribe(serviceName string, callback func(events []Event)) error
 	Unsubscribe(serviceName string) error
 	Register(serviceRegistration cim.ServiceRegistration) error
 	Deregister(serviceID string) error
@@ -0,0 +1,285 @@
+package static
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cirno-im"
+	"cirno-im/logger"
+	"cirno-im/naming"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultInterval 检查文件是否变化的间隔
+const DefaultInterval = time.Second
+
+// Entry 文件中的一条服务记录
+type Entry struct {
+	ID        string            `yaml:"id" json:"id"`
+	Name      string            `yaml:"name" json:"name"`
+	Namespace string            `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Address   string            `yaml:"address" json:"address"`
+	Port      int               `yaml:"port" json:"port"`
+	Protocol  string            `yaml:"protocol" json:"protocol"`
+	Tags      []string          `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Meta      map[string]string `yaml:"meta,omitempty" json:"meta,omitempty"`
+}
+
+// File 服务文件的格式，根据扩展名使用json或yaml
+type File struct {
+	Services []Entry `yaml:"services" json:"services"`
+}
+
+// Naming 从本地文件读取服务列表，用于本地开发和测试；
+// Register/Deregister会改写文件，多个进程同时改写时不保证不丢失更新
+type Naming struct {
+	sync.Mutex
+	path     string
+	interval time.Duration
+	watches  map[string]chan struct{}
+}
+
+// NewNaming 创建一个静态的Naming，文件不存在时视为空列表
+func NewNaming(path string) (naming.Naming, error) {
+	n := &Naming{
+		path:     path,
+		interval: DefaultInterval,
+		watches:  make(map[string]chan struct{}),
+	}
+	if _, err := n.load(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *Naming) load() (*File, error) {
+	data, err := os.ReadFile(n.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	if n.isJSON() {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (n *Naming) save(file *File) error {
+	var (
+		data []byte
+		err  error
+	)
+	if n.isJSON() {
+		data, err = json.MarshalIndent(file, "", "  ")
+	} else {
+		data, err = yaml.Marshal(file)
+	}
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(n.path), filepath.Base(n.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), n.path)
+}
+
+func (n *Naming) isJSON() bool {
+	return strings.EqualFold(filepath.Ext(n.path), ".json")
+}
+
+func (n *Naming) Find(name string, tags ...string) ([]cim.ServiceRegistration, error) {
+	file, err := n.load()
+	if err != nil {
+		return nil, err
+	}
+	return find(file, name, tags...), nil
+}
+
+func find(file *File, name string, tags ...string) []cim.ServiceRegistration {
+	services := make([]cim.ServiceRegistration, 0)
+	for _, e := range file.Services {
+		if e.Name != name || !hasTags(e.Tags, tags) {
+			continue
+		}
+		services = append(services, &naming.DefaultService{
+			Id:        e.ID,
+			Name:      e.Name,
+			Namespace: e.Namespace,
+			Address:   e.Address,
+			Port:      e.Port,
+			Protocol:  e.Protocol,
+			Tags:      e.Tags,
+			Meta:      e.Meta,
+		})
+	}
+	return services
+}
+
+func hasTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Naming) Register(s cim.ServiceRegistration) error {
+	n.Lock()
+	defer n.Unlock()
+	file, err := n.load()
+	if err != nil {
+		return err
+	}
+	entry := Entry{
+		ID:        s.ServiceID(),
+		Name:      s.ServiceName(),
+		Namespace: s.GetNamespace(),
+		Address:   s.PublicAddress(),
+		Port:      s.PublicPort(),
+		Protocol:  s.GetProtocol(),
+		Tags:      s.GetTags(),
+		Meta:      s.GetMetadata(),
+	}
+	for i, e := range file.Services {
+		if e.ID == entry.ID {
+			file.Services[i] = entry
+			return n.save(file)
+		}
+	}
+	file.Services = append(file.Services, entry)
+	return n.save(file)
+}
+
+func (n *Naming) Deregister(serviceID string) error {
+	n.Lock()
+	defer n.Unlock()
+	file, err := n.load()
+	if err != nil {
+		return err
+	}
+	services := file.Services[:0]
+	for _, e := range file.Services {
+		if e.ID != serviceID {
+			services = append(services, e)
+		}
+	}
+	file.Services = services
+	return n.save(file)
+}
+
+// Subscribe 定时检查文件的修改时间，服务有变化时回调
+func (n *Naming) Subscribe(serviceName string, callback func([]naming.Event)) error {
+	n.Lock()
+	defer n.Unlock()
+	if _, ok := n.watches[serviceName]; ok {
+		return errors.New("serviceName has already been registered")
+	}
+	w := &watch{
+		service:  serviceName,
+		callback: callback,
+		quit:     make(chan struct{}),
+	}
+	// 在返回之前记录当前的状态，之后的变化都会回调
+	if file, err := n.load(); err == nil {
+		w.last = find(file, serviceName)
+	}
+	if info, err := os.Stat(n.path); err == nil {
+		w.modTime, w.size = info.ModTime(), info.Size()
+	}
+	n.watches[serviceName] = w.quit
+
+	go n.watch(w)
+	return nil
+}
+
+func (n *Naming) Unsubscribe(serviceName string) error {
+	n.Lock()
+	defer n.Unlock()
+	quit, ok := n.watches[serviceName]
+	delete(n.watches, serviceName)
+	if ok {
+		close(quit)
+	}
+	return nil
+}
+
+type watch struct {
+	service  string
+	callback func([]naming.Event)
+	quit     chan struct{}
+	modTime  time.Time
+	size     int64
+	last     []cim.ServiceRegistration
+}
+
+func (n *Naming) watch(w *watch) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.quit:
+			logger.Infof("watch %s stopped", w.service)
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(n.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Warn(err)
+			continue
+		}
+		var (
+			modTime time.Time
+			size    int64
+		)
+		if info != nil {
+			modTime, size = info.ModTime(), info.Size()
+		}
+		if modTime.Equal(w.modTime) && size == w.size {
+			continue
+		}
+		w.modTime, w.size = modTime, size
+
+		file, err := n.load()
+		if err != nil {
+			logger.Warn(err)
+			continue
+		}
+		services := find(file, w.service)
+		events := naming.Diff(w.last, services)
+		w.last = services
+		if len(events) > 0 {
+			w.callback(events)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package static
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cirno-im/naming"
+	"github.com/stretchr/testify/assert"
+)
+
+const services = `
+services:
+  - id: chat_1
+    name: chat
+    address: 127.0.0.1
+    port: 8005
+    protocol: tcp
+    tags: [a]
+  - id: login_1
+    name: login
+    address: 127.0.0.1
+    port: 8006
+    protocol: tcp
+`
+
+func TestNaming(t *testing.T) {
+	for _, name := range []string{"naming.yaml", "naming.json"} {
+		path := filepath.Join(t.TempDir(), name)
+		if filepath.Ext(name) == ".yaml" {
+			assert.Nil(t, os.WriteFile(path, []byte(services), 0644))
+		}
+		ns, err := NewNaming(path)
+		assert.Nil(t, err)
+		ns.(*Naming).interval = time.Millisecond * 20
+
+		if filepath.Ext(name) == ".json" {
+			// json文件从空开始，通过Register写入
+			_ = ns.Register(naming.NewEntry("chat_1", "chat", "tcp", "127.0.0.1", 8005))
+		}
+		srvs, err := ns.Find("chat")
+		assert.Nil(t, err)
+		assert.Len(t, srvs, 1)
+		assert.Equal(t, "127.0.0.1:8005", srvs[0].DialURL())
+
+		got := make(chan []naming.Event, 4)
+		assert.Nil(t, ns.Subscribe("chat", func(events []naming.Event) { got <- events }))
+		assert.NotNil(t, ns.Subscribe("chat", func(events []naming.Event) {}))
+
+		// 其它服务的变化不会回调
+		_ = ns.Register(naming.NewEntry("login_2", "login", "tcp", "127.0.0.1", 8007))
+		assert.Nil(t, ns.Register(naming.NewEntry("chat_2", "chat", "tcp", "127.0.0.1", 8008)))
+		select {
+		case events := <-got:
+			assert.Len(t, events, 1)
+			assert.Equal(t, naming.EventAdded, events[0].Type)
+			assert.Equal(t, "chat_2", events[0].Service.ServiceID())
+		case <-time.After(time.Second):
+			t.Fatal("no callback after register")
+		}
+
+		assert.Nil(t, ns.Deregister("chat_1"))
+		select {
+		case events := <-got:
+			assert.Len(t, events, 1)
+			assert.Equal(t, naming.EventRemoved, events[0].Type)
+			assert.Equal(t, "chat_1", events[0].Service.ServiceID())
+		case <-time.After(time.Second):
+			t.Fatal("no callback after deregister")
+		}
+		assert.Nil(t, ns.Unsubscribe("chat"))
+	}
+}
+
+func TestFindTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "naming.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(services), 0644))
+	ns, err := NewNaming(path)
+	assert.Nil(t, err)
+
+	srvs, _ := ns.Find("chat", "a")
+	assert.Len(t, srvs, 1)
+	srvs, _ = ns.Find("chat", "b")
+	assert.Len(t, srvs, 0)
+
+	assert.Nil(t, os.WriteFile(path, []byte("services: ["), 0644))
+	_, err = NewNaming(path)
+	assert.NotNil(t, err)
+}
@@ -1,19 +1,31 @@
 package cim
 
 import (
+	"cirno-im/trace"
 	"cirno-im/wire/pkt"
+	"context"
 	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 	"sync"
 )
 
 var ErrSessionLost = errors.New("err:session lost")
 
+// RequestTypes 命令登记的请求类型，见wire/command
+type RequestTypes interface {
+	RequestType(command string) (proto.Message, bool)
+}
+
 // Router defines
 type Router struct {
 	middlewares []HandlerFunc
 	handlers    *FuncTree
 	pool        sync.Pool
+	// types 不为空时HandleRequest会检查声明的请求类型
+	types RequestTypes
 }
 
 // NewRouter NewRouter
@@ -38,6 +50,32 @@ func (r *Router) Handle(commond string, handlers ...HandlerFunc) {
 	r.handlers.Add(commond, handlers...)
 }
 
+// SetRequestTypes 设置命令的请求类型，之后通过HandleRequest注册的处理器都会被检查
+func (r *Router) SetRequestTypes(types RequestTypes) {
+	r.types = types
+}
+
+// HandleRequest 注册处理器并声明它读取的请求类型，命令没有登记或者类型不一致时panic
+func (r *Router) HandleRequest(command string, req proto.Message, handlers ...HandlerFunc) {
+	if r.types != nil {
+		expected, ok := r.types.RequestType(command)
+		if !ok {
+			panic(fmt.Sprintf("command %s is not registered", command))
+		}
+		if typeName(expected) != typeName(req) {
+			panic(fmt.Sprintf("command %s expects request %s, but the handler reads %s", command, typeName(expected), typeName(req)))
+		}
+	}
+	r.Handle(command, handlers...)
+}
+
+func typeName(msg proto.Message) string {
+	if msg == nil {
+		return "<nil>"
+	}
+	return string(msg.ProtoReflect().Descriptor().FullName())
+}
+
 // Serve a packet from client
 func (r *Router) Serve(packet *pkt.LogicPkt, dispatcher Dispatcher, cache SessionStorage, session Session) error {
 	if dispatcher == nil {
@@ -53,7 +91,18 @@ func (r *Router) Serve(packet *pkt.LogicPkt, dispatcher Dispatcher, cache Sessio
 	ctx.SessionStorage = cache
 	ctx.session = session
 
+	// 延续网关中创建的span
+	spanCtx, span := trace.Tracer().Start(trace.Extract(context.Background(), packet), packet.Command,
+		oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(
+			attribute.String("cim.channel_id", packet.ChannelID),
+			attribute.Int64("cim.sequence", int64(packet.Sequence)),
+		))
+	ctx.ctx = spanCtx
+
 	r.serveContext(ctx)
+	span.SetAttributes(attribute.String("cim.status", ctx.status.String()))
+	span.End()
 	// Put Context to Pool
 	r.pool.Put(ctx)
 	return nil
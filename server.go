@@ -102,6 +102,10 @@ type DialerContext struct {
 	Name    string
 	Address string
 	Timeout time.Duration
+	// Index 连接在连接池中的序号，0为主连接
+	Index int
+	// Namespace 发起连接的服务所在的命名空间
+	Namespace string
 }
 
 type Meta map[string]string
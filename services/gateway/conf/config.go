@@ -2,8 +2,10 @@ package conf
 
 import (
 	"fmt"
+	"time"
 
 	"cirno-im/logger"
+	"github.com/go-redis/redis/v7"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/spf13/viper"
 )
@@ -19,19 +21,53 @@ type Config struct {
 	Domain          string   `yaml:"Domain" `
 	ConsulURL       string   `yaml:"ConsulURL"`
 	MonitorPort     int      `yaml:"MonitorPort" `
-	AppSecret       string   `yaml:"AppSecret"`
 	LogLevel        string   `yaml:"LogLevel" `
 	MessageGPool    int      `yaml:"MessageGPool" default:"10000"`
 	ConnectionGPool int      `yaml:"ConnectionGPool" default:"15000"`
+	// Selector 选择逻辑服务的方式：route(缺省)、hash、consistent_hash、weighted_round_robin、least_inflight
+	Selector string `yaml:"Selector"`
+	// RetryBuffer 逻辑服务不可用时每个服务最多缓存的消息数，0表示不缓存
+	RetryBuffer int           `yaml:"RetryBuffer"`
+	RetryWindow time.Duration `yaml:"RetryWindow"`
+	// WarmUp 新发现的逻辑服务的预热时长
+	WarmUp time.Duration `yaml:"WarmUp"`
+	// PoolSize 到每个逻辑服务节点的连接数
+	PoolSize int `yaml:"PoolSize"`
+	// Transport 与逻辑服务通信的方式：tcp(缺省)、broker
+	Transport string `yaml:"Transport"`
+	BrokerDir string `yaml:"BrokerDir"`
+	// AdminToken 监控端口上管理接口的token，为空时不开放
+	AdminToken string `yaml:"AdminToken"`
+	// Namespace 服务所在的命名空间，只与同一个命名空间中的服务通信
+	Namespace string `yaml:"Namespace"`
+	// CompressThreshold body超过这个大小时才压缩，为0时使用默认值
+	CompressThreshold int `yaml:"CompressThreshold"`
+	// PushHistory 每个channel保留的最近推送数，供客户端请求重发，为0时使用默认值
+	PushHistory int `yaml:"PushHistory"`
+	// TokenKeys 验证token的密钥文件，格式见token.LoadKeys
+	TokenKeys string `yaml:"TokenKeys"`
+	// RoyalURL 不为空时定期从royal服务拉取公钥，RoyalToken 为royal管理接口的token
+	RoyalURL   string `yaml:"RoyalURL"`
+	RoyalToken string `yaml:"RoyalToken"`
+	// TokenAudience 不为空时要求token的aud与之相同
+	TokenAudience string `yaml:"TokenAudience"`
+	// KeyRefresh 重新加载密钥的间隔，KeyGrace 密钥被轮换后仍然有效的时长，为0时使用默认值
+	KeyRefresh time.Duration `yaml:"KeyRefresh"`
+	KeyGrace   time.Duration `yaml:"KeyGrace"`
+	// RedisAddrs token撤销列表所在的redis，为空时不检查撤销
+	RedisAddrs string `yaml:"RedisAddrs"`
 }
 
 // Init InitConfig
 func Init(file string) (*Config, error) {
-	viper.SetConfigFile(file)
 	viper.SetConfigName("conf")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("/etc/conf")
 	viper.AddConfigPath("F:\\code\\golang\\cirno-im\\services\\gateway")
+	// SetConfigName会清空SetConfigFile设置的文件，指定了文件时需要在它之后设置
+	if file != "" {
+		viper.SetConfigFile(file)
+	}
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("conf file not found: %w", err)
 	}
@@ -49,3 +85,19 @@ func Init(file string) (*Config, error) {
 
 	return &config, nil
 }
+
+func InitRedis(addr string, pass string) (*redis.Client, error) {
+	redisdb := redis.NewClient(&redis.Options{
+		Addr:         addr,
+		Password:     pass,
+		DialTimeout:  time.Second * 5,
+		ReadTimeout:  time.Second * 5,
+		WriteTimeout: time.Second * 5,
+	})
+
+	_, err := redisdb.Ping().Result()
+	if err != nil {
+		return nil, err
+	}
+	return redisdb, nil
+}
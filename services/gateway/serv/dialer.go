@@ -2,6 +2,7 @@ package serv
 
 import (
 	cim "cirno-im"
+	"cirno-im/container"
 	"cirno-im/logger"
 	"cirno-im/tcp"
 	"cirno-im/wire/pkt"
@@ -25,7 +26,10 @@ func (d *TcpDialer) DialAndHandshake(ctx cim.DialerContext) (net.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	req := &pkt.InnerHandshakeRequest{ServiceID: d.ServiceID}
+	req := &pkt.InnerHandshakeRequest{
+		ServiceID: container.PoolMemberID(d.ServiceID, ctx.Index),
+		Namespace: ctx.Namespace,
+	}
 	logger.Infof("send req %v", req)
 	//将自己的ServiceId发送给对方
 	bts, _ := proto.Marshal(req)
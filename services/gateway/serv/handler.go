@@ -6,10 +6,17 @@ import (
 	"cirno-im/constants"
 	"cirno-im/container"
 	"cirno-im/logger"
+	"cirno-im/storage"
+	"cirno-im/trace"
 	"cirno-im/wire"
 	"cirno-im/wire/pkt"
 	"cirno-im/wire/token"
+	"context"
 	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"regexp"
 	"time"
 )
@@ -21,7 +28,21 @@ var log = logger.WithFields(logger.Fields{
 
 type Handler struct {
 	ServiceID string
-	AppSecret string
+	// Keys 验证登录token的密钥
+	Keys *token.KeyStore
+	// Revokes token撤销列表，为空时不检查
+	Revokes *storage.RevokeList
+	// Container 转发消息使用的容器，为空时使用默认容器
+	Container *container.Container
+	// CompressThreshold body超过这个大小时才压缩，为0时使用pkt.DefaultCompressThreshold
+	CompressThreshold int
+}
+
+func (h *Handler) container() *container.Container {
+	if h.Container == nil {
+		return container.Default()
+	}
+	return h.Container
 }
 
 func (h *Handler) Accept(conn cim.Conn, timeout time.Duration) (string, cim.Meta, error) {
@@ -39,8 +60,14 @@ func (h *Handler) Accept(conn cim.Conn, timeout time.Duration) (string, cim.Meta
 	if err != nil {
 		return "", nil, err
 	}
-	buffer := bytes.NewBuffer(frame.GetPayload())
-	req, err := pkt.MustReadLogicPkt(buffer)
+	// 浏览器客户端使用OpText帧发送JSON格式的包，之后这个连接都使用相同的格式
+	text := frame.GetOpCode() == cim.OpText
+	var req *pkt.LogicPkt
+	if text {
+		req, err = pkt.ReadText(frame.GetPayload())
+	} else {
+		req, err = pkt.MustReadLogicPkt(bytes.NewBuffer(frame.GetPayload()))
+	}
 	if err != nil {
 		return "", nil, err
 	}
@@ -48,7 +75,7 @@ func (h *Handler) Accept(conn cim.Conn, timeout time.Duration) (string, cim.Meta
 	if req.Command != wire.CommandLoginSignIn {
 		resp := pkt.NewFrom(&req.Header)
 		resp.Status = pkt.Status_InvalidCommand
-		err = conn.WriteFrame(cim.OpBinary, pkt.Marshal(resp))
+		err = writeResp(conn, text, resp)
 		if err != nil {
 			log.Errorln(err)
 		}
@@ -61,12 +88,15 @@ func (h *Handler) Accept(conn cim.Conn, timeout time.Duration) (string, cim.Meta
 	if err != nil {
 		return "", nil, err
 	}
-	//4.使用默认的DefaultSecret 解析token
-	tk, err := token.Parse(token.DefaultSecret, login.Token)
+	//4.按app和kid查找密钥解析token
+	tk, err := h.Keys.Parse(login.Token)
+	if err == nil && h.Revokes != nil {
+		err = h.Revokes.Check(tk)
+	}
 	if err != nil {
 		resp := pkt.NewFrom(&req.Header)
 		resp.Status = pkt.Status_Unauthorized
-		err = conn.WriteFrame(cim.OpBinary, pkt.Marshal(resp))
+		err = writeResp(conn, text, resp)
 		if err != nil {
 			log.Errorln(err)
 		}
@@ -75,26 +105,99 @@ func (h *Handler) Accept(conn cim.Conn, timeout time.Duration) (string, cim.Meta
 	//5.生成全局唯一的ChannelID
 	channelID := generateChannelID(h.ServiceID, tk.Account)
 
+	//6.协商body的压缩和加密方式，JSON格式的连接不支持
+	if text {
+		login.Compressions = nil
+		login.PublicKey = nil
+	}
+	codec, publicKey, err := h.negotiate(&login)
+	if err != nil {
+		resp := pkt.NewFrom(&req.Header)
+		resp.Status = pkt.Status_InvalidPacketBody
+		_ = writeResp(conn, text, resp)
+		return "", nil, err
+	}
+
 	req.ChannelID = channelID
+	stripReservedMeta(req)
 	req.WriteBody(&pkt.Session{
-		ChannelID: channelID,
-		GateID:    h.ServiceID,
-		Account:   tk.Account,
-		RemoteIP:  getIP(conn.RemoteAddr().String()),
-		App:       tk.App,
+		ChannelID:   channelID,
+		GateID:      h.ServiceID,
+		Account:     tk.Account,
+		RemoteIP:    getIP(conn.RemoteAddr().String()),
+		App:         tk.App,
+		Compression: codec.Compression,
+		PublicKey:   publicKey,
 	})
 
-	//6.login转发给Login服务
-	err = container.Forward(wire.SNLogin, req)
+	//7.login转发给Login服务
+	h.container().SetChannelCodec(channelID, codec)
+	h.container().OpenSequence(channelID, req.Sequence)
+	err = h.forward(wire.SNLogin, req)
 	if err != nil {
+		h.container().SetChannelCodec(channelID, nil)
+		h.container().CloseSequence(channelID)
 		return "", nil, err
 	}
-	return channelID, nil, nil
+	meta := cim.Meta{
+		constants.MetaKeyApp:     tk.App,
+		constants.MetaKeyAccount: tk.Account,
+		constants.MetaKeyFrame:   constants.FrameBinary,
+		constants.MetaKeyTokenID: tk.ID,
+	}
+	if text {
+		meta[constants.MetaKeyFrame] = constants.FrameText
+	}
+	return channelID, meta, nil
+}
+
+// writeResp 以客户端请求时的帧格式回复
+func writeResp(conn cim.Conn, text bool, resp *pkt.LogicPkt) error {
+	if !text {
+		return conn.WriteFrame(cim.OpBinary, pkt.Marshal(resp))
+	}
+	payload, err := pkt.MarshalText(resp)
+	if err != nil {
+		return err
+	}
+	return conn.WriteFrame(cim.OpText, payload)
+}
+
+// negotiate 根据登录请求选出压缩算法，客户端带了公钥时生成加密使用的密钥，返回网关的公钥
+func (h *Handler) negotiate(login *pkt.LoginRequest) (*pkt.Codec, []byte, error) {
+	var (
+		key       []byte
+		publicKey []byte
+	)
+	if len(login.PublicKey) > 0 {
+		private, err := pkt.GenerateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err = pkt.SharedKey(private, login.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey = private.PublicKey().Bytes()
+	}
+	codec, err := pkt.NewCodec(pkt.Negotiate(login.Compressions), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	codec.Threshold = h.CompressThreshold
+	return codec, publicKey, nil
 }
 
 func (h *Handler) Receive(agent cim.Agent, payload []byte) {
-	buf := bytes.NewBuffer(payload)
-	packet, err := pkt.Read(buf)
+	var (
+		packet interface{}
+		err    error
+	)
+	if agent.GetMetadata()[constants.MetaKeyFrame] == constants.FrameText {
+		packet, err = pkt.ReadText(payload)
+	} else {
+		packet, err = pkt.Read(bytes.NewBuffer(payload))
+	}
 	if err != nil {
 		log.Errorln(err)
 		return
@@ -110,17 +213,36 @@ func (h *Handler) Receive(agent cim.Agent, payload []byte) {
 	}
 	if logicPkt, ok := packet.(*pkt.LogicPkt); ok {
 		logicPkt.ChannelID = agent.ID()
+		// 还原客户端压缩或加密过的body，逻辑服务只处理原始内容
+		codec, _ := h.container().ChannelCodec(agent.ID())
+		if err := logicPkt.SetCodec(codec).RestoreBody(); err != nil {
+			log.WithField("id", agent.ID()).Warn(err)
+			return
+		}
+		logicPkt.SetCodec(nil)
 
 		messageInTotal.WithLabelValues(h.ServiceID, wire.SNTGateway, logicPkt.Command).Inc()
 		messageInFlowBytes.WithLabelValues(h.ServiceID, wire.SNTGateway, logicPkt.Command).Add(float64(len(payload)))
 
-		// 把meta注入到header中
+		// 客户端没有收到响应时会用相同的Sequence重试，已经转发过的包直接丢弃，响应可以通过重发找回
+		if !h.container().CheckSequence(agent.ID(), logicPkt.Sequence) {
+			messageDuplicateTotal.WithLabelValues(h.ServiceID, wire.SNTGateway, logicPkt.Command).Inc()
+			log.WithField("id", agent.ID()).Debugf("drop duplicate %s sequence %d", logicPkt.Command, logicPkt.Sequence)
+			return
+		}
+		if logicPkt.Command == wire.CommandGatewayResend {
+			h.resend(agent.ID(), logicPkt)
+			return
+		}
+
+		// 把meta注入到header中，客户端传入的保留字段会被删除
+		stripReservedMeta(logicPkt)
 		if agent.GetMetadata() != nil {
 			logicPkt.AddStringMeta(constants.MetaKeyApp, agent.GetMetadata()[constants.MetaKeyApp])
 			logicPkt.AddStringMeta(constants.MetaKeyAccount, agent.GetMetadata()[constants.MetaKeyAccount])
 		}
 
-		err := container.Forward(logicPkt.ServiceName(), logicPkt)
+		err := h.forward(logicPkt.ServiceName(), logicPkt)
 		if err != nil {
 			logger.WithFields(logger.Fields{
 				"module": "handler",
@@ -132,11 +254,62 @@ func (h *Handler) Receive(agent cim.Agent, payload []byte) {
 	}
 }
 
+// resend 重新推送客户端缺失的消息，然后回复仍保留的pushSeq范围
+func (h *Handler) resend(channelID string, req *pkt.LogicPkt) {
+	var body pkt.ResendRequest
+	resp := pkt.NewFrom(&req.Header)
+	resp.Flag = pkt.Flag_Response
+	if err := req.ReadBody(&body); err != nil {
+		resp.Status = pkt.Status_InvalidPacketBody
+		resp.WriteBody(&pkt.ErrorResponse{Message: err.Error()})
+	} else if first, last, err := h.container().Resend(channelID, body.From, body.To); err != nil {
+		resp.Status = pkt.Status_SystemException
+		resp.WriteBody(&pkt.ErrorResponse{Message: err.Error()})
+	} else {
+		resp.WriteBody(&pkt.ResendResponse{First: first, Last: last})
+	}
+	if err := h.container().PushChannel(channelID, resp); err != nil {
+		log.WithField("id", channelID).Warn(err)
+	}
+}
+
+// stripReservedMeta 删除由网关和逻辑服务设置的meta，防止客户端伪造响应地址、身份或者链路上下文
+func stripReservedMeta(packet *pkt.LogicPkt) {
+	keys := []string{wire.MetaDestServer, wire.MetaDestChannels, wire.MetaCallFrom, constants.MetaKeyApp, constants.MetaKeyAccount}
+	for _, key := range append(keys, otel.GetTextMapPropagator().Fields()...) {
+		if _, ok := packet.GetMeta(key); ok {
+			packet.DelMeta(key)
+		}
+	}
+}
+
+// forward 开启一个span并注入到packet的Meta中，然后转发给逻辑服务
+func (h *Handler) forward(serviceName string, packet *pkt.LogicPkt) error {
+	ctx, span := trace.Tracer().Start(context.Background(), packet.Command,
+		oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(
+			attribute.String("cim.channel_id", packet.ChannelID),
+			attribute.Int64("cim.sequence", int64(packet.Sequence)),
+			attribute.String("cim.service_name", serviceName),
+		))
+	defer span.End()
+	trace.Inject(ctx, packet)
+
+	err := h.container().Forward(serviceName, packet)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
 func (h *Handler) DisConnect(id string) error {
 	log.Infof("disconnect %s", id)
+	h.container().SetChannelCodec(id, nil)
+	h.container().CloseSequence(id)
 
 	logout := pkt.New(wire.CommandLoginSignOut, pkt.WithChannel(id))
-	err := container.Forward(wire.SNLogin, logout)
+	err := h.container().Forward(wire.SNLogin, logout)
 	if err != nil {
 		logger.WithFields(logger.Fields{
 			"module": "handler",
@@ -0,0 +1,30 @@
+package serv
+
+import (
+	"testing"
+
+	"cirno-im/constants"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestStripReservedMeta(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	packet := pkt.New(wire.CommandChatUserTalk)
+	packet.AddStringMeta(wire.MetaCallFrom, "chat01")
+	packet.AddStringMeta(wire.MetaDestServer, "gateway02")
+	packet.AddStringMeta(wire.MetaDestChannels, "other")
+	packet.AddStringMeta(constants.MetaKeyAccount, "admin")
+	packet.AddStringMeta("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	packet.AddStringMeta("custom", "value")
+
+	stripReservedMeta(packet)
+	assert.Equal(t, 1, len(packet.Meta))
+	val, _ := packet.GetString("custom")
+	assert.Equal(t, "value", val)
+	_, ok := packet.GetString(wire.MetaCallFrom)
+	assert.False(t, ok)
+}
@@ -0,0 +1,95 @@
+package serv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cirno-im/container"
+	"cirno-im/wire/rpc"
+	"cirno-im/wire/token"
+	"github.com/go-resty/resty/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+// KeyLoader 加载验证token的密钥，来源为密钥文件和royal服务，至少需要配置一个
+// HS256的密钥只能配置在密钥文件中，royal只下发公钥
+type KeyLoader struct {
+	File     string
+	RoyalURL string
+	cli      *resty.Client
+}
+
+// NewKeyLoader royalToken为royal管理接口的token
+func NewKeyLoader(file, royalURL, royalToken string) *KeyLoader {
+	cli := resty.New().SetRetryCount(3).SetTimeout(time.Second * 5)
+	cli.SetHeader("Accept", "application/x-protobuf")
+	cli.SetHeader(container.AdminTokenHeader, royalToken)
+	return &KeyLoader{
+		File:     file,
+		RoyalURL: strings.TrimRight(royalURL, "/"),
+		cli:      cli,
+	}
+}
+
+func (l *KeyLoader) Load(ctx context.Context) ([]token.Key, error) {
+	var keys []token.Key
+	if l.File != "" {
+		list, err := token.LoadKeys(l.File)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, list...)
+	}
+	if l.RoyalURL != "" {
+		list, err := l.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, list...)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no token key is configured")
+	}
+	return keys, nil
+}
+
+func (l *KeyLoader) fetch(ctx context.Context) ([]token.Key, error) {
+	response, err := l.cli.R().SetContext(ctx).Get(l.RoyalURL + "/api/token/keys")
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode() != 200 {
+		return nil, fmt.Errorf("KeyLoader.fetch response.StatusCode() = %d, want 200", response.StatusCode())
+	}
+	var resp rpc.ListTokenKeyResp
+	if err = proto.Unmarshal(response.Body(), &resp); err != nil {
+		return nil, err
+	}
+	keys := make([]token.Key, len(resp.Keys))
+	for i, k := range resp.Keys {
+		keys[i] = token.Key{App: k.App, ID: k.Kid, Alg: k.Alg, PublicKey: k.PublicKey}
+	}
+	return keys, nil
+}
+
+// WatchKeys 定期重新加载密钥，加载失败时保留当前的密钥
+func WatchKeys(ctx context.Context, store *token.KeyStore, loader *KeyLoader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		keys, err := loader.Load(ctx)
+		if err == nil {
+			err = store.Update(keys)
+		}
+		if err != nil {
+			log.WithField("func", "WatchKeys").Warn(err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package serv
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cirno-im/container"
+	"cirno-im/wire/rpc"
+	"cirno-im/wire/token"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestKeyLoader(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`[{"app":"cim","kid":"k1","alg":"HS256","secret":"s1"}]`), 0o600))
+
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	publicKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	royal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/token/keys", r.URL.Path)
+		if r.Header.Get(container.AdminTokenHeader) != "admin" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := proto.Marshal(&rpc.ListTokenKeyResp{Keys: []*rpc.TokenKey{
+			{App: "cim", Kid: "k2", Alg: token.AlgEdDSA, PublicKey: publicKey},
+		}})
+		_, _ = w.Write(body)
+	}))
+	defer royal.Close()
+
+	keys, err := NewKeyLoader(file, "", "").Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []token.Key{{App: "cim", ID: "k1", Alg: token.AlgHS256, Secret: "s1"}}, keys)
+
+	keys, err = NewKeyLoader(file, royal.URL+"/", "admin").Load(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, "k2", keys[1].ID)
+	assert.Equal(t, publicKey, keys[1].PublicKey)
+	assert.Nil(t, token.NewKeyStore("", 0).Update(keys))
+
+	_, err = NewKeyLoader("", royal.URL, "wrong").Load(context.Background())
+	assert.NotNil(t, err)
+	_, err = NewKeyLoader("", "", "").Load(context.Background())
+	assert.NotNil(t, err)
+}
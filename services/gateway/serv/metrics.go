@@ -17,6 +17,12 @@ var messageInFlowBytes = promauto.NewCounterVec(prometheus.CounterOpts{
 	Help:      "网关接收消息字节数",
 }, []string{"serviceId", "serviceName", "command"})
 
+var messageDuplicateTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cim",
+	Name:      "message_duplicate_total",
+	Help:      "网关丢弃的客户端重复发送的消息数",
+}, []string{"serviceId", "serviceName", "command"})
+
 var noServerFoundErrorTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Namespace: "cim",
 	Name:      "no_server_found_error_total",
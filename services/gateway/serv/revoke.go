@@ -0,0 +1,33 @@
+package serv
+
+import (
+	"context"
+
+	cim "cirno-im"
+	"cirno-im/constants"
+	"cirno-im/storage"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"cirno-im/wire/token"
+)
+
+// WatchRevocations 订阅撤销消息，把本网关上对应的连接踢下线
+func (h *Handler) WatchRevocations(ctx context.Context, revokes *storage.RevokeList) {
+	revokes.Subscribe(ctx, h.kickOut)
+}
+
+func (h *Handler) kickOut(r *token.Revocation) {
+	ids := h.container().FindChannels(func(meta cim.Meta) bool {
+		return r.Match(meta[constants.MetaKeyApp], meta[constants.MetaKeyAccount], meta[constants.MetaKeyTokenID])
+	})
+	for _, id := range ids {
+		notify := pkt.New(wire.CommandLoginKickOut, pkt.WithChannel(id))
+		notify.Flag = pkt.Flag_Push
+		notify.WriteBody(&pkt.KickOutNotify{ChannelID: id})
+		if err := h.container().KickOut(id, notify); err != nil {
+			log.WithField("id", id).Warn(err)
+			continue
+		}
+		log.Infof("kick out %s of %s/%s: token revoked", id, r.App, r.Account)
+	}
+}
@@ -0,0 +1,89 @@
+package serv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	cim "cirno-im"
+	"cirno-im/constants"
+	"cirno-im/container"
+	"cirno-im/naming"
+	"cirno-im/storage"
+	"cirno-im/tcp"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"cirno-im/wire/token"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+// mapServer 记录容器设置的ChannelMap，测试中直接添加channel
+type mapServer struct {
+	cim.Server
+	channels cim.ChannelMap
+}
+
+func (s *mapServer) SetChannelMap(m cim.ChannelMap) {
+	s.channels = m
+	s.Server.SetChannelMap(m)
+}
+
+func TestKickOutRevoked(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer cli.Close()
+	revokes := storage.NewRevokeList(cli)
+
+	srv := &mapServer{Server: tcp.NewServer("127.0.0.1:0", &naming.DefaultService{Id: "gateway01", Name: wire.SNWGateway})}
+	ct := container.New()
+	assert.Nil(t, ct.Init(srv))
+	addChannel := func(id, app string) net.Conn {
+		local, remote := net.Pipe()
+		ch := cim.NewChannel(id, cim.Meta{
+			constants.MetaKeyApp:     app,
+			constants.MetaKeyAccount: "test1",
+			constants.MetaKeyTokenID: "t1",
+		}, tcp.NewConn(local), nil)
+		go func() { _ = ch.ReadLoop(nil) }()
+		srv.channels.Add(ch)
+		return remote
+	}
+	remote := addChannel("ch1", "cim")
+	other := addChannel("ch2", "other")
+	defer other.Close()
+
+	h := &Handler{ServiceID: "gateway01", Container: ct}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.WatchRevocations(ctx, revokes)
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub(storage.RevokeChannel)[storage.RevokeChannel] == 1
+	}, time.Second, time.Millisecond*10)
+
+	assert.Nil(t, revokes.Revoke(&token.Revocation{App: "cim", TokenID: "t1", At: time.Now().Unix()}, time.Hour))
+
+	_ = remote.SetReadDeadline(time.Now().Add(time.Second))
+	frame, err := tcp.NewConn(remote).ReadFrame()
+	assert.Nil(t, err)
+	notify, err := pkt.MustReadLogicPkt(bytes.NewBuffer(frame.GetPayload()))
+	assert.Nil(t, err)
+	assert.Equal(t, wire.CommandLoginKickOut, notify.Command)
+	assert.Equal(t, pkt.Flag_Push, notify.Flag)
+	var body pkt.KickOutNotify
+	assert.Nil(t, notify.ReadBody(&body))
+	assert.Equal(t, "ch1", body.ChannelID)
+	// 推送之后连接被关闭
+	_, err = remote.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+
+	// 其它app中相同jti的连接不受影响
+	_ = other.SetReadDeadline(time.Now().Add(time.Millisecond * 50))
+	_, err = other.Read(make([]byte, 1))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+}
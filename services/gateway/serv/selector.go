@@ -29,9 +29,9 @@ func NewRouteSelector(configPath string) (*RouteSelector, error) {
 // Lookup a server
 func (s *RouteSelector) Lookup(header *pkt.Header, srvs []cim.Service) string {
 	// 1. 从header中读取Meta信息
-	app, _ := pkt.FindMeta(header.Meta, constants.MetaKeyApp)
-	account, _ := pkt.FindMeta(header.Meta, constants.MetaKeyAccount)
-	if app == nil || account == nil {
+	app, ok1 := pkt.FindString(header.Meta, constants.MetaKeyApp)
+	account, ok2 := pkt.FindString(header.Meta, constants.MetaKeyAccount)
+	if !ok1 || !ok2 {
 		ri := rand.Intn(len(srvs))
 		return srvs[ri].ServiceID()
 	}
@@ -41,16 +41,16 @@ func (s *RouteSelector) Lookup(header *pkt.Header, srvs []cim.Service) string {
 	})
 
 	// 2. 判断是否命中白名单
-	zone, ok := s.route.Whitelist[app.(string)]
+	zone, ok := s.route.Whitelist[app]
 	if !ok { // 未命中情况
 		var key string
 		switch s.route.RouteBy {
 		case constants.MetaKeyApp:
-			key = app.(string)
+			key = app
 		case constants.MetaKeyAccount:
-			key = account.(string)
+			key = account
 		default:
-			key = account.(string)
+			key = account
 		}
 		// 3. 通过权重计算出zone
 		slot := hashcode(key) % len(s.route.Slots)
@@ -68,7 +68,7 @@ func (s *RouteSelector) Lookup(header *pkt.Header, srvs []cim.Service) string {
 		return srvs[ri].ServiceID()
 	}
 	// 5. 从zoneSrvs中选中一个服务
-	srv := selectSrvs(zoneSrvs, account.(string))
+	srv := selectSrvs(zoneSrvs, account)
 	return srv.ServiceID()
 }
 
@@ -7,15 +7,20 @@ import (
 	_ "net/http/pprof"
 	"time"
 
+	"cirno-im/broker/local"
+	"cirno-im/constants"
 	"cirno-im/container"
 	"cirno-im/logger"
 	"cirno-im/naming"
 	"cirno-im/naming/consul"
+	"cirno-im/naming/factory"
 	"cirno-im/services/gateway/conf"
 	"cirno-im/services/gateway/serv"
+	"cirno-im/storage"
 	"cirno-im/tcp"
 	"cirno-im/websocket"
 	"cirno-im/wire"
+	"cirno-im/wire/token"
 
 	"github.com/spf13/cobra"
 )
@@ -24,6 +29,7 @@ import (
 
 // ServerStartOptions ServerStartOptions
 type ServerStartOptions struct {
+	naming   factory.Options
 	config   string
 	protocol string
 	route    string
@@ -43,6 +49,7 @@ func NewServerStartCMD(ctx context.Context, version string) *cobra.Command {
 	cmd.PersistentFlags().StringVarP(&opts.config, "config", "c", "./gateway/conf.yaml", "Config file")
 	cmd.PersistentFlags().StringVarP(&opts.route, "route", "r", "./gateway/route.json", "route file")
 	cmd.PersistentFlags().StringVarP(&opts.protocol, "protocol", "p", "ws", "protocol of ws or tcp")
+	factory.AddFlags(cmd.PersistentFlags(), &opts.naming)
 	return cmd
 }
 
@@ -57,23 +64,47 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 		Filename: "./data/gateway.log",
 	})
 
+	keyStore, keyLoader, err := loadKeys(ctx, config)
+	if err != nil {
+		return err
+	}
+	keyRefresh := config.KeyRefresh
+	if keyRefresh <= 0 {
+		keyRefresh = constants.DefaultKeyRefresh
+	}
+	go serv.WatchKeys(ctx, keyStore, keyLoader, keyRefresh)
+
 	handler := &serv.Handler{
 		ServiceID: config.ServiceID,
-		AppSecret: config.AppSecret,
+		Keys:      keyStore,
+		Container: container.Default(),
+
+		CompressThreshold: config.CompressThreshold,
+	}
+	if config.RedisAddrs != "" {
+		rdb, err := conf.InitRedis(config.RedisAddrs, "")
+		if err != nil {
+			return err
+		}
+		handler.Revokes = storage.NewRevokeList(rdb)
+		go handler.WatchRevocations(ctx, handler.Revokes)
+	} else {
+		logger.Warn("RedisAddrs is empty, token revocation is disabled")
 	}
 	meta := make(map[string]string)
-	meta[consul.KeyHealthURL] = fmt.Sprintf("http://%s:%d/health", config.PublicAddress, config.MonitorPort)
+	meta[consul.KeyHealthURL] = fmt.Sprintf("http://%s:%d/health/ready", config.PublicAddress, config.MonitorPort)
 	meta["domain"] = config.Domain
 
 	var srv cim.Server
 	service := &naming.DefaultService{
-		Id:       config.ServiceID,
-		Name:     config.ServiceName,
-		Address:  config.PublicAddress,
-		Port:     config.PublicPort,
-		Protocol: opts.protocol,
-		Tags:     config.Tags,
-		Meta:     meta,
+		Id:        config.ServiceID,
+		Name:      config.ServiceName,
+		Namespace: config.Namespace,
+		Address:   config.PublicAddress,
+		Port:      config.PublicPort,
+		Protocol:  opts.protocol,
+		Tags:      config.Tags,
+		Meta:      meta,
 	}
 	srvOpts := []cim.ServerOption{
 		cim.WithConnectionGPool(config.ConnectionGPool), cim.WithMessageGPool(config.MessageGPool),
@@ -94,20 +125,68 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 	_ = container.Init(srv, wire.SNChat, wire.SNLogin)
 	//health check and metric report
 	container.EnableMonitor(fmt.Sprintf(":%d", config.MonitorPort))
+	container.SetAdminToken(config.AdminToken)
 
-	ns, err := consul.NewNaming(config.ConsulURL)
+	ns, err := factory.New(opts.naming, config.ConsulURL)
 	if err != nil {
 		return err
 	}
+	defer factory.Close(ns)
 	container.SetServiceNaming(ns)
 	// set a dialer
 	container.SetDialer(serv.NewDialer(config.ServiceID))
-	// use routeSelector
-	selector, err := serv.NewRouteSelector(opts.route)
+	// use routeSelector by default
+	var selector container.Selector
+	if config.Selector == "" || config.Selector == "route" {
+		selector, err = serv.NewRouteSelector(opts.route)
+	} else {
+		selector, err = container.NewSelector(config.Selector)
+	}
 	if err != nil {
 		return err
 	}
 	container.SetSelector(selector)
+	container.SetRetry(config.RetryBuffer, config.RetryWindow)
+	if config.WarmUp > 0 {
+		container.SetWarmUp(config.WarmUp)
+	}
+	if config.PoolSize > 0 {
+		container.SetPoolSize(config.PoolSize)
+	}
+	container.SetPushHistory(config.PushHistory)
+	if config.Transport == container.TransportBroker {
+		dir := config.BrokerDir
+		if dir == "" {
+			dir = constants.DefaultBrokerDir
+		}
+		b, err := local.New(dir)
+		if err != nil {
+			return err
+		}
+		defer b.Close()
+		container.SetBroker(b, nil)
+	}
 
 	return container.Start()
 }
+
+// loadKeys 按配置加载验证token的密钥
+func loadKeys(ctx context.Context, config *conf.Config) (*token.KeyStore, *serv.KeyLoader, error) {
+	keyGrace := config.KeyGrace
+	if keyGrace <= 0 {
+		keyGrace = constants.DefaultKeyGrace
+	}
+	keyLoader := serv.NewKeyLoader(config.TokenKeys, config.RoyalURL, config.RoyalToken)
+	keys, err := keyLoader.Load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.TokenAudience == "" {
+		logger.Warn("TokenAudience is empty, the aud of tokens is not checked")
+	}
+	keyStore := token.NewKeyStore(config.TokenAudience, keyGrace)
+	if err = keyStore.Update(keys); err != nil {
+		return nil, nil, err
+	}
+	return keyStore, keyLoader, nil
+}
@@ -0,0 +1,35 @@
+package gateway
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"cirno-im/services/gateway/conf"
+	"cirno-im/wire/token"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoadShippedConfig 使用仓库中的配置加载密钥，网关从services目录启动
+func TestLoadShippedConfig(t *testing.T) {
+	wd, _ := os.Getwd()
+	assert.Nil(t, os.Chdir(".."))
+	defer func() { _ = os.Chdir(wd) }()
+
+	config, err := conf.Init("./gateway/conf.yaml")
+	assert.Nil(t, err)
+	keys, _, err := loadKeys(context.Background(), config)
+	assert.Nil(t, err)
+
+	// demo中签发的token能够通过验证
+	tk, _ := token.Generate(token.DefaultSecret, &token.Token{
+		Account: "test1",
+		App:     "cim",
+		Exp:     time.Now().Add(time.Hour).Unix(),
+		Iat:     time.Now().Unix(),
+	})
+	got, err := keys.Parse(tk)
+	assert.Nil(t, err)
+	assert.Equal(t, "test1", got.Account)
+}
@@ -13,6 +13,8 @@ type Config struct {
 	Listen    string `default:":8100"`
 	ConsulURL string `default:"localhost:8500"`
 	LogLevel  string `default:"INFO"`
+	// Namespace 只返回这个命名空间中的网关
+	Namespace string
 }
 
 func (c Config) String() string {
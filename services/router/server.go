@@ -6,7 +6,8 @@ import (
 	"path"
 
 	"cirno-im/logger"
-	"cirno-im/naming/consul"
+	"cirno-im/naming"
+	"cirno-im/naming/factory"
 	"cirno-im/services/router/apis"
 	"cirno-im/services/router/conf"
 	"cirno-im/services/router/ipregion"
@@ -17,6 +18,7 @@ import (
 
 // ServerStartOptions ServerStartOptions
 type ServerStartOptions struct {
+	naming factory.Options
 	config string
 	data   string
 }
@@ -34,6 +36,7 @@ func NewServerStartCmd(ctx context.Context, version string) *cobra.Command {
 	}
 	cmd.PersistentFlags().StringVarP(&opts.config, "config", "c", "./router/conf.yaml", "Config file")
 	cmd.PersistentFlags().StringVarP(&opts.data, "data", "d", "./router/data", "data path")
+	factory.AddFlags(cmd.PersistentFlags(), &opts.naming)
 	return cmd
 }
 
@@ -64,13 +67,14 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 		return err
 	}
 
-	ns, err := consul.NewNaming(config.ConsulURL)
+	ns, err := factory.New(opts.naming, config.ConsulURL)
 	if err != nil {
 		return err
 	}
+	defer factory.Close(ns)
 
 	router := apis.RouterApi{
-		Naming:   ns,
+		Naming:   naming.WithNamespace(ns, config.Namespace),
 		IpRegion: region,
 		Config: conf.Router{
 			Mapping: mappings,
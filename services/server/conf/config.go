@@ -30,6 +30,51 @@ type Config struct {
 	RedisAddrs    string
 	RoyalURL      string
 	LogLevel      string `default:"INFO"`
+	// RateLimit 每个账号每秒允许发送的消息数
+	RateLimit float64 `default:"20"`
+	RateBurst int     `default:"40"`
+	// Dependencies 依赖的其它逻辑服务，服务间通过容器的TCP连接调用
+	Dependencies []string
+	// Selector 调用依赖服务时选择节点的方式，见container.NewSelector
+	Selector string
+	// WarmUp 新发现的依赖服务的预热时长
+	WarmUp time.Duration
+	// PoolSize 到每个依赖服务节点的连接数
+	PoolSize int
+	// Transport 与网关通信的方式：tcp(缺省)、broker
+	Transport string
+	BrokerDir string
+	// AdminToken 监控端口上管理接口的token，为空时不开放
+	AdminToken string
+	// Namespace 服务所在的命名空间，只与同一个命名空间中的服务通信
+	Namespace string
+	// Routes 按指令覆盖中间件的配置，没有配置的指令使用全局的限流
+	Routes []RouteConfig
+}
+
+// RouteConfig 单个指令的中间件配置
+type RouteConfig struct {
+	Command string
+	// RateLimit 每个账号每秒允许的请求数，为0时使用全局配置
+	RateLimit float64
+	RateBurst int
+	// Apps 允许访问的app，为空时不限制
+	Apps []string
+}
+
+// Route 返回指令的中间件配置
+func (c *Config) Route(command string) RouteConfig {
+	route := RouteConfig{Command: command, RateLimit: c.RateLimit, RateBurst: c.RateBurst}
+	for _, r := range c.Routes {
+		if r.Command != command {
+			continue
+		}
+		if r.RateLimit > 0 {
+			route.RateLimit, route.RateBurst = r.RateLimit, r.RateBurst
+		}
+		route.Apps = r.Apps
+	}
+	return route
 }
 
 func (c Config) String() string {
@@ -39,13 +84,21 @@ func (c Config) String() string {
 
 // Init InitConfig
 func Init(file string) (*Config, error) {
-	viper.SetConfigFile(file)
 	viper.SetConfigName("conf")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("F:\\code\\golang\\cirno-im\\services\\server")
 	viper.AddConfigPath("/etc/conf")
+	// SetConfigName会清空SetConfigFile设置的文件，指定了文件时需要在它之后设置
+	if file != "" {
+		viper.SetConfigFile(file)
+	}
 
 	var config Config
+	// envconfig会用默认值覆盖已有的字段，先取默认值和环境变量，再由配置文件覆盖
+	err := envconfig.Process("cim", &config)
+	if err != nil {
+		return nil, err
+	}
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Warn(err)
 	} else {
@@ -53,10 +106,6 @@ func Init(file string) (*Config, error) {
 			return nil, err
 		}
 	}
-	err := envconfig.Process("cim", &config)
-	if err != nil {
-		return nil, err
-	}
 	if config.ServiceID == "" {
 		localIP := cim.GetLocalIP()
 		config.ServiceID = fmt.Sprintf("server_%s", strings.ReplaceAll(localIP, ".", ""))
@@ -0,0 +1,58 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+
+	"cirno-im/naming/static"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutes(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(strings.NewReader(`
+ServiceID: chat01
+RateLimit: 10
+RateBurst: 20
+Routes:
+  - Command: chat.group.talk
+    RateLimit: 1
+    RateBurst: 2
+    Apps:
+      - cim
+`))
+	assert.Nil(t, err)
+	var config Config
+	assert.Nil(t, v.Unmarshal(&config))
+
+	route := config.Route("chat.group.talk")
+	assert.Equal(t, 1.0, route.RateLimit)
+	assert.Equal(t, 2, route.RateBurst)
+	assert.Equal(t, []string{"cim"}, route.Apps)
+
+	route = config.Route("chat.user.talk")
+	assert.Equal(t, 10.0, route.RateLimit)
+	assert.Equal(t, 20, route.RateBurst)
+	assert.Empty(t, route.Apps)
+}
+
+// 本地开发的静态服务列表需要与每个服务的配置一致
+func TestShippedNaming(t *testing.T) {
+	ns, err := static.NewNaming("../../naming.yaml")
+	assert.Nil(t, err)
+	ports := make(map[int]string)
+	for file, name := range map[string]string{"../conf.yaml": "chat", "../login.yaml": "login"} {
+		config, err := Init(file)
+		assert.Nil(t, err)
+		srvs, err := ns.Find(name)
+		assert.Nil(t, err)
+		if assert.Len(t, srvs, 1) {
+			assert.Equal(t, config.ServiceID, srvs[0].ServiceID())
+			assert.Equal(t, config.PublicPort, srvs[0].PublicPort())
+		}
+		assert.Empty(t, ports[config.PublicPort])
+		ports[config.PublicPort] = config.ServiceID
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"time"
 )
 
-var ErrNoDestination = errors.New("dest is empty")
+var (
+	ErrNoDestination  = errors.New("dest is empty")
+	ErrGroupEncrypted = errors.New("end-to-end encryption is only supported in one-to-one chat")
+)
 
 type ChatHandler struct {
 	msgService   service.Message
@@ -46,14 +49,15 @@ func (h *ChatHandler) DoUserTalk(ctx cim.Context) {
 
 	//保存离线消息
 	sendTime := time.Now().UnixNano()
-	response, err := h.msgService.InsertUser(ctx.Session().GetApp(), &rpc.InsertMessageReq{
+	response, err := h.msgService.InsertUser(ctx.Context(), ctx.Session().GetApp(), &rpc.InsertMessageReq{
 		Sender:   ctx.Session().GetAccount(),
 		Dest:     receiver,
 		SendTime: sendTime,
 		Message: &rpc.Message{
-			Type:  req.GetType(),
-			Body:  req.GetBody(),
-			Extra: req.GetExtra(),
+			Type:      req.GetType(),
+			Body:      req.GetBody(),
+			Extra:     req.GetExtra(),
+			Encrypted: req.GetEncrypted(),
 		},
 	})
 	if err != nil {
@@ -71,6 +75,7 @@ func (h *ChatHandler) DoUserTalk(ctx cim.Context) {
 			Extra:     req.GetExtra(),
 			Sender:    ctx.Session().GetAccount(),
 			SendTime:  sendTime,
+			Encrypted: req.GetEncrypted(),
 		}, location); err != nil {
 			responseWithError(ctx, pkt.Status_SystemException, err)
 			return
@@ -100,11 +105,17 @@ func (h *ChatHandler) DoGroupTalk(ctx cim.Context) {
 		return
 	}
 
+	// 群聊没有端到端加密，拒绝密文以免保存成无法解密的消息
+	if req.GetEncrypted() {
+		responseWithError(ctx, pkt.Status_InvalidPacketBody, ErrGroupEncrypted)
+		return
+	}
+
 	group := ctx.Header().GetDest()
 	sendTime := time.Now().UnixNano()
 
 	//保存离线消息
-	resp, err := h.msgService.InsertGroup(ctx.Session().GetApp(), &rpc.InsertMessageReq{
+	resp, err := h.msgService.InsertGroup(ctx.Context(), ctx.Session().GetApp(), &rpc.InsertMessageReq{
 		Sender:   ctx.Session().GetAccount(),
 		Dest:     group,
 		SendTime: sendTime,
@@ -120,7 +131,7 @@ func (h *ChatHandler) DoGroupTalk(ctx cim.Context) {
 	}
 
 	//读取成员列表
-	membersResp, err := h.groupService.Members(ctx.Session().GetApp(), &rpc.GroupMembersReq{
+	membersResp, err := h.groupService.Members(ctx.Context(), ctx.Session().GetApp(), &rpc.GroupMembersReq{
 		GroupId: group,
 	})
 	if err != nil {
@@ -166,7 +177,7 @@ func (h *ChatHandler) DoTalkAck(ctx cim.Context) {
 		responseWithError(ctx, pkt.Status_InvalidPacketBody, err)
 		return
 	}
-	err := h.msgService.SetAck(ctx.Session().GetApp(), &rpc.AckMessageReq{
+	err := h.msgService.SetAck(ctx.Context(), ctx.Session().GetApp(), &rpc.AckMessageReq{
 		Account:   ctx.Session().GetAccount(),
 		MessageId: req.GetMessageID(),
 	})
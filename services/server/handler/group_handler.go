@@ -24,7 +24,7 @@ func (h *GroupHandler) DoCreate(ctx cim.Context) {
 		responseWithError(ctx, pkt.Status_InvalidPacketBody, err)
 		return
 	}
-	resp, err := h.groupService.Create(ctx.Session().GetApp(), &rpc.CreateGroupReq{
+	resp, err := h.groupService.Create(ctx.Context(), ctx.Session().GetApp(), &rpc.CreateGroupReq{
 		Name:         req.GetName(),
 		Avatar:       req.GetAvatar(),
 		Introduction: req.GetIntroduction(),
@@ -68,7 +68,7 @@ func (h *GroupHandler) DoJoin(ctx cim.Context) {
 		responseWithError(ctx, pkt.Status_InvalidPacketBody, err)
 		return
 	}
-	err := h.groupService.Join(ctx.Session().GetApp(), &rpc.JoinGroupReq{
+	err := h.groupService.Join(ctx.Context(), ctx.Session().GetApp(), &rpc.JoinGroupReq{
 		Account: req.Account,
 		GroupId: req.GetGroupId(),
 	})
@@ -90,7 +90,7 @@ func (h *GroupHandler) DoQuit(ctx cim.Context) {
 		responseWithError(ctx, pkt.Status_InvalidPacketBody, err)
 		return
 	}
-	err := h.groupService.Quit(ctx.Session().GetApp(), &rpc.QuitGroupReq{
+	err := h.groupService.Quit(ctx.Context(), ctx.Session().GetApp(), &rpc.QuitGroupReq{
 		Account: req.Account,
 		GroupId: req.GetGroupId(),
 	})
@@ -111,14 +111,14 @@ func (h *GroupHandler) DoDetail(ctx cim.Context) {
 		responseWithError(ctx, pkt.Status_InvalidPacketBody, err)
 		return
 	}
-	resp, err := h.groupService.Detail(ctx.Session().GetApp(), &rpc.GetGroupReq{
+	resp, err := h.groupService.Detail(ctx.Context(), ctx.Session().GetApp(), &rpc.GetGroupReq{
 		GroupId: req.GetGroupId(),
 	})
 	if err != nil {
 		responseWithError(ctx, pkt.Status_SystemException, err)
 		return
 	}
-	membersResp, err := h.groupService.Members(ctx.Session().GetApp(), &rpc.GroupMembersReq{
+	membersResp, err := h.groupService.Members(ctx.Context(), ctx.Session().GetApp(), &rpc.GroupMembersReq{
 		GroupId: req.GetGroupId(),
 	})
 	if err != nil {
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	cim "cirno-im"
+	"cirno-im/services/server/service"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"cirno-im/wire/rpc"
+	"errors"
+	"fmt"
+)
+
+// KeyHandler 上传和获取端到端加密使用的公钥，私钥只保存在客户端
+type KeyHandler struct {
+	keyService service.Key
+}
+
+func NewKeyHandler(keyService service.Key) *KeyHandler {
+	return &KeyHandler{
+		keyService: keyService,
+	}
+}
+
+// DoUpload 只能上传自己的公钥，Account以登录的账号为准
+func (h *KeyHandler) DoUpload(ctx cim.Context) {
+	var req pkt.KeyBundle
+	if err := ctx.ReadBody(&req); err != nil {
+		responseWithError(ctx, pkt.Status_InvalidPacketBody, err)
+		return
+	}
+	if len(req.IdentityKey) == 0 || len(req.SignedPreKey) == 0 {
+		responseWithError(ctx, pkt.Status_InvalidPacketBody, errors.New("identityKey and signedPreKey are required"))
+		return
+	}
+	err := h.keyService.Upload(ctx.Context(), ctx.Session().GetApp(), &rpc.KeyBundle{
+		Account:      ctx.Session().GetAccount(),
+		IdentityKey:  req.GetIdentityKey(),
+		SignedPreKey: req.GetSignedPreKey(),
+		Signature:    req.GetSignature(),
+	})
+	if err != nil {
+		responseWithError(ctx, pkt.Status_SystemException, err)
+		return
+	}
+	_ = ctx.Resp(pkt.Status_Success, nil)
+}
+
+func (h *KeyHandler) DoFetch(ctx cim.Context) {
+	var req pkt.KeyFetchRequest
+	if err := ctx.ReadBody(&req); err != nil {
+		responseWithError(ctx, pkt.Status_InvalidPacketBody, err)
+		return
+	}
+	if len(req.Accounts) == 0 || len(req.Accounts) > wire.KeyMaxCountPerFetch {
+		responseWithError(ctx, pkt.Status_InvalidPacketBody, fmt.Errorf("the number of accounts must be between 1 and %d", wire.KeyMaxCountPerFetch))
+		return
+	}
+	resp, err := h.keyService.Fetch(ctx.Context(), ctx.Session().GetApp(), &rpc.FetchKeyReq{
+		Accounts: req.Accounts,
+	})
+	if err != nil {
+		responseWithError(ctx, pkt.Status_SystemException, err)
+		return
+	}
+	var bundles = make([]*pkt.KeyBundle, len(resp.Bundles))
+	for i, b := range resp.Bundles {
+		bundles[i] = &pkt.KeyBundle{
+			Account:      b.Account,
+			IdentityKey:  b.IdentityKey,
+			SignedPreKey: b.SignedPreKey,
+			Signature:    b.Signature,
+			UpdatedAt:    b.UpdatedAt,
+		}
+	}
+	_ = ctx.Resp(pkt.Status_Success, &pkt.KeyFetchResponse{
+		Bundles: bundles,
+	})
+}
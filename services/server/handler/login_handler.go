@@ -3,6 +3,7 @@ package handler
 import (
 	cim "cirno-im"
 	"cirno-im/logger"
+	"cirno-im/wire"
 	"cirno-im/wire/pkt"
 	"errors"
 )
@@ -36,9 +37,12 @@ func (h *LoginHandler) DoSyncLogin(ctx cim.Context) {
 		logger.Errorln("location,err:", err)
 		return
 	}
-	if location != nil {
+	if location != nil && location.ChannelID != session.ChannelID {
 		//3.通知这个用户下线
-		err := ctx.Dispatch(&pkt.KickOutNotify{ChannelID: location.ChannelID})
+		notify := pkt.New(wire.CommandLoginKickOut, pkt.WithChannel(location.ChannelID))
+		notify.Flag = pkt.Flag_Push
+		notify.WriteBody(&pkt.KickOutNotify{ChannelID: location.ChannelID})
+		err := ctx.Push(location.GateID, []string{location.ChannelID}, notify)
 		if err != nil {
 			responseWithError(ctx, pkt.Status_SystemException, err)
 			logger.Errorln("dispatch,err:", err)
@@ -54,7 +58,11 @@ func (h *LoginHandler) DoSyncLogin(ctx cim.Context) {
 	}
 
 	//5.返回一个登陆成功的信息
-	var resp = &pkt.LoginResponse{ChannelID: session.GetChannelID()}
+	var resp = &pkt.LoginResponse{
+		ChannelID:   session.GetChannelID(),
+		Compression: session.GetCompression(),
+		PublicKey:   session.GetPublicKey(),
+	}
 	err = ctx.Resp(pkt.Status_Success, resp)
 	if err != nil {
 		responseWithError(ctx, pkt.Status_SystemException, err)
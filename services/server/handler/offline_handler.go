@@ -24,7 +24,7 @@ func (h *OfflineHandler) DoSyncIndex(ctx cim.Context) {
 		responseWithError(ctx, pkt.Status_InvalidPacketBody, err)
 		return
 	}
-	resp, err := h.msgService.GetMessageIndex(ctx.Session().GetApp(), &rpc.GetOfflineMessageIndexReq{
+	resp, err := h.msgService.GetMessageIndex(ctx.Context(), ctx.Session().GetApp(), &rpc.GetOfflineMessageIndexReq{
 		Account:   ctx.Session().GetAccount(),
 		MessageId: req.GetMessageId(),
 	})
@@ -61,7 +61,7 @@ func (h *OfflineHandler) DoSyncContent(ctx cim.Context) {
 		responseWithError(ctx, pkt.Status_InvalidPacketBody, errors.New("empty MessageIds"))
 		return
 	}
-	resp, err := h.msgService.GetMessageContent(ctx.Session().GetApp(), &rpc.GetOfflineMessageContentReq{
+	resp, err := h.msgService.GetMessageContent(ctx.Context(), ctx.Session().GetApp(), &rpc.GetOfflineMessageContentReq{
 		MessageIds: req.MessageIds,
 	})
 	if err != nil {
@@ -75,6 +75,7 @@ func (h *OfflineHandler) DoSyncContent(ctx cim.Context) {
 			Type:      val.Type,
 			Body:      val.Body,
 			Extra:     val.Extra,
+			Encrypted: val.Encrypted,
 		}
 	}
 	err = ctx.Resp(pkt.Status_Success, &pkt.MessageContentResp{
@@ -0,0 +1,40 @@
+package serv
+
+import (
+	cim "cirno-im"
+	"cirno-im/container"
+	"cirno-im/logger"
+	"cirno-im/tcp"
+	"cirno-im/wire/pkt"
+	"google.golang.org/protobuf/proto"
+	"net"
+)
+
+// TcpDialer 与依赖的逻辑服务建立连接并握手
+type TcpDialer struct {
+	ServiceID string
+}
+
+func NewDialer(serviceId string) cim.Dialer {
+	return &TcpDialer{
+		ServiceID: serviceId,
+	}
+}
+
+func (d *TcpDialer) DialAndHandshake(ctx cim.DialerContext) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", ctx.Address, ctx.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	req := &pkt.InnerHandshakeRequest{
+		ServiceID: container.PoolMemberID(d.ServiceID, ctx.Index),
+		Namespace: ctx.Namespace,
+	}
+	logger.Infof("send req %v", req)
+	bts, _ := proto.Marshal(req)
+	err = tcp.WriteFrame(conn, cim.OpBinary, bts)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
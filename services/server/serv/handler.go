@@ -7,6 +7,7 @@ import (
 	"cirno-im/logger"
 	"cirno-im/wire"
 	"cirno-im/wire/pkt"
+	"context"
 	"errors"
 	"google.golang.org/protobuf/proto"
 	"strings"
@@ -19,11 +20,17 @@ var log = logger.WithFields(logger.Fields{
 })
 
 type ServerDispatcher struct {
+	Container *container.Container
 }
 
 func (d *ServerDispatcher) Push(gateway string, channels []string, p *pkt.LogicPkt) error {
 	p.AddStringMeta(wire.MetaDestChannels, strings.Join(channels, ","))
-	return container.Push(gateway, p)
+	return d.Container.Push(gateway, p)
+}
+
+// Request 调用其它逻辑服务，并等待响应
+func (d *ServerDispatcher) Request(ctx context.Context, serviceName string, p *pkt.LogicPkt) (*pkt.LogicPkt, error) {
+	return d.Container.Request(ctx, serviceName, p)
 }
 
 // DisConnect default listener
@@ -36,13 +43,15 @@ type ServHandler struct {
 	r          *cim.Router
 	cache      cim.SessionStorage
 	dispatcher cim.Dispatcher
+	container  *container.Container
 }
 
-func NewServHandler(r *cim.Router, cache cim.SessionStorage) *ServHandler {
+func NewServHandler(r *cim.Router, cache cim.SessionStorage, ct *container.Container) *ServHandler {
 	return &ServHandler{
 		r:          r,
 		cache:      cache,
-		dispatcher: &ServerDispatcher{},
+		dispatcher: &ServerDispatcher{Container: ct},
+		container:  ct,
 	}
 }
 
@@ -61,6 +70,11 @@ func (h *ServHandler) Accept(conn cim.Conn, timeout time.Duration) (string, cim.
 		return "", nil, err
 	}
 	log.Info("Accept -- ", req.ServiceID)
+	// 拒绝其它命名空间的服务
+	if err := h.container.CheckNamespace(req.Namespace); err != nil {
+		log.Warnf("refuse %s: %v", req.ServiceID, err)
+		return "", nil, err
+	}
 	return req.ServiceID, nil, nil
 }
 
@@ -73,10 +87,10 @@ func (h *ServHandler) Receive(agent cim.Agent, payload []byte) {
 	}
 	var session *pkt.Session
 	if packet.Command == wire.CommandLoginSignIn {
-		server, _ := packet.GetMeta(wire.MetaDestServer)
+		server, _ := packet.GetString(wire.MetaDestServer)
 		session = &pkt.Session{
 			ChannelID: packet.ChannelID,
-			GateID:    server.(string),
+			GateID:    server,
 			Tags:      []string{"AuthGenerated"},
 		}
 	} else {
@@ -2,12 +2,14 @@ package server
 
 import (
 	cim "cirno-im"
+	"cirno-im/broker/local"
 	"cirno-im/constants"
 	"cirno-im/container"
 	"cirno-im/logger"
 	"cirno-im/middleware"
 	"cirno-im/naming"
 	"cirno-im/naming/consul"
+	"cirno-im/naming/factory"
 	"cirno-im/services/server/conf"
 	"cirno-im/services/server/handler"
 	"cirno-im/services/server/serv"
@@ -15,14 +17,17 @@ import (
 	"cirno-im/storage"
 	"cirno-im/tcp"
 	"cirno-im/wire"
+	"cirno-im/wire/command"
+	"cirno-im/wire/pkt"
 	"context"
-	"github.com/go-resty/resty/v2"
+	"fmt"
 	"github.com/spf13/cobra"
 	"strings"
 )
 
 // ServerStartOptions ServerStartOptions
 type ServerStartOptions struct {
+	naming      factory.Options
 	config      string
 	serviceName string
 }
@@ -40,6 +45,7 @@ func NewServerStartCMD(ctx context.Context, version string) *cobra.Command {
 	}
 	cmd.PersistentFlags().StringVarP(&opts.config, "conf", "c", "conf.yaml", "Config file")
 	cmd.PersistentFlags().StringVarP(&opts.serviceName, "serviceName", "s", "chat", "defined a services name,option is login or chat")
+	factory.AddFlags(cmd.PersistentFlags(), &opts.naming)
 	return cmd
 }
 
@@ -54,21 +60,39 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 		Filename: "./data/server.log",
 	})
 
+	ns, err := factory.New(opts.naming, config.ConsulURL)
+	if err != nil {
+		return err
+	}
+	defer factory.Close(ns)
+
 	var groupService service.Group
 	var messageService service.Message
+	var keyService service.Key
 	if strings.TrimSpace(config.RoyalURL) != "" {
 		groupService = service.NewGroupService(config.RoyalURL)
 		messageService = service.NewMessageService(config.RoyalURL)
+		keyService = service.NewKeyService(config.RoyalURL)
 	} else {
-		srvRecord := &resty.SRVRecord{
-			Domain:  "consul",
-			Service: wire.SNService,
-		}
-		groupService = service.NewGroupServiceWithSRV("http", srvRecord)
-		messageService = service.NewMessageServiceWithSRV("http", srvRecord)
+		// 只访问同一个命名空间中的royal
+		royalNaming := naming.WithNamespace(ns, config.Namespace)
+		groupService = service.NewGroupServiceWithNaming(royalNaming)
+		messageService = service.NewMessageServiceWithNaming(royalNaming)
+		keyService = service.NewKeyServiceWithNaming(royalNaming)
 	}
 	r := cim.NewRouter()
-	r.Use(middleware.Recover())
+	r.SetRequestTypes(command.Default)
+	r.Use(middleware.Recover(), middleware.Metrics(), middleware.AccessLog())
+	// 客户端可以访问的指令都经过鉴权、限流和包体校验，每个指令使用自己的限流器和配置
+	routeMiddlewares := func(cmd string, handler cim.HandlerFunc) []cim.HandlerFunc {
+		route := config.Route(cmd)
+		return []cim.HandlerFunc{
+			middleware.Auth(route.Apps...),
+			middleware.RateLimit(route.RateLimit, route.RateBurst),
+			middleware.Validate(command.Default),
+			handler,
+		}
+	}
 
 	// login
 	loginHandler := handler.NewLoginHandler()
@@ -76,35 +100,44 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 	r.Handle(wire.CommandLoginSignOut, loginHandler.DoSysLogout)
 	// talk
 	chatHandler := handler.NewChatHandler(messageService, groupService)
-	r.Handle(wire.CommandChatUserTalk, chatHandler.DoUserTalk)
-	r.Handle(wire.CommandChatGroupTalk, chatHandler.DoGroupTalk)
-	r.Handle(wire.CommandChatTalkAck, chatHandler.DoTalkAck)
+	r.HandleRequest(wire.CommandChatUserTalk, &pkt.MessageRequest{}, routeMiddlewares(wire.CommandChatUserTalk, chatHandler.DoUserTalk)...)
+	r.HandleRequest(wire.CommandChatGroupTalk, &pkt.MessageRequest{}, routeMiddlewares(wire.CommandChatGroupTalk, chatHandler.DoGroupTalk)...)
+	r.HandleRequest(wire.CommandChatTalkAck, &pkt.MessageAckRequest{}, routeMiddlewares(wire.CommandChatTalkAck, chatHandler.DoTalkAck)...)
 	// group
 	groupHandler := handler.NewGroupHandler(groupService)
-	r.Handle(wire.CommandGroupCreate, groupHandler.DoCreate)
-	r.Handle(wire.CommandGroupJoin, groupHandler.DoJoin)
-	r.Handle(wire.CommandGroupQuit, groupHandler.DoQuit)
-	r.Handle(wire.CommandGroupDetail, groupHandler.DoDetail)
+	r.HandleRequest(wire.CommandGroupCreate, &pkt.GroupCreateRequest{}, routeMiddlewares(wire.CommandGroupCreate, groupHandler.DoCreate)...)
+	r.HandleRequest(wire.CommandGroupJoin, &pkt.GroupJoinReq{}, routeMiddlewares(wire.CommandGroupJoin, groupHandler.DoJoin)...)
+	r.HandleRequest(wire.CommandGroupQuit, &pkt.GroupQuitReq{}, routeMiddlewares(wire.CommandGroupQuit, groupHandler.DoQuit)...)
+	r.HandleRequest(wire.CommandGroupDetail, &pkt.GroupGetReq{}, routeMiddlewares(wire.CommandGroupDetail, groupHandler.DoDetail)...)
+
+	// end-to-end encryption keys
+	keyHandler := handler.NewKeyHandler(keyService)
+	r.HandleRequest(wire.CommandKeyUpload, &pkt.KeyBundle{}, routeMiddlewares(wire.CommandKeyUpload, keyHandler.DoUpload)...)
+	r.HandleRequest(wire.CommandKeyFetch, &pkt.KeyFetchRequest{}, routeMiddlewares(wire.CommandKeyFetch, keyHandler.DoFetch)...)
 
 	// offline
 	offlineHandler := handler.NewOfflineHandler(messageService)
-	r.Handle(wire.CommandOfflineIndex, offlineHandler.DoSyncIndex)
-	r.Handle(wire.CommandOfflineContent, offlineHandler.DoSyncContent)
+	r.HandleRequest(wire.CommandOfflineIndex, &pkt.MessageIndexReq{}, routeMiddlewares(wire.CommandOfflineIndex, offlineHandler.DoSyncIndex)...)
+	r.HandleRequest(wire.CommandOfflineContent, &pkt.MessageContentReq{}, routeMiddlewares(wire.CommandOfflineContent, offlineHandler.DoSyncContent)...)
 
 	rdb, err := conf.InitRedis(config.RedisAddrs, "")
 	if err != nil {
 		return err
 	}
 	cache := storage.NewRedisStorage(rdb)
-	servhandler := serv.NewServHandler(r, cache)
+	servhandler := serv.NewServHandler(r, cache, container.Default())
 
 	service := &naming.DefaultService{
-		Id:       config.ServiceID,
-		Name:     opts.serviceName,
-		Address:  config.PublicAddress,
-		Port:     config.PublicPort,
-		Protocol: string(wire.ProtocolTCP),
-		Tags:     config.Tags,
+		Id:        config.ServiceID,
+		Name:      opts.serviceName,
+		Namespace: config.Namespace,
+		Address:   config.PublicAddress,
+		Port:      config.PublicPort,
+		Protocol:  string(wire.ProtocolTCP),
+		Tags:      config.Tags,
+		Meta: map[string]string{
+			consul.KeyHealthURL: fmt.Sprintf("http://%s:%d/health/ready", config.PublicAddress, config.MonitorPort),
+		},
 	}
 	srv := tcp.NewServer(config.Listen, service)
 
@@ -113,14 +146,40 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 	srv.SetMessageListener(servhandler)
 	srv.SetStateListener(servhandler)
 
-	if err := container.Init(srv); err != nil {
+	if err := container.Init(srv, config.Dependencies...); err != nil {
 		return err
 	}
-
-	ns, err := consul.NewNaming(config.ConsulURL)
+	//health check and metric report
+	container.EnableMonitor(fmt.Sprintf(":%d", config.MonitorPort))
+	container.SetAdminToken(config.AdminToken)
+	container.AddHealthCheck("redis", func(ctx context.Context) error {
+		return rdb.WithContext(ctx).Ping().Err()
+	})
+	container.SetDialer(serv.NewDialer(config.ServiceID))
+	selector, err := container.NewSelector(config.Selector)
 	if err != nil {
 		return err
 	}
+	container.SetSelector(selector)
+	if config.WarmUp > 0 {
+		container.SetWarmUp(config.WarmUp)
+	}
+	if config.PoolSize > 0 {
+		container.SetPoolSize(config.PoolSize)
+	}
+	if config.Transport == container.TransportBroker {
+		dir := config.BrokerDir
+		if dir == "" {
+			dir = constants.DefaultBrokerDir
+		}
+		b, err := local.New(dir)
+		if err != nil {
+			return err
+		}
+		defer b.Close()
+		container.SetBroker(b, servhandler)
+	}
+
 	container.SetServiceNaming(ns)
 
 	return container.Start()
@@ -2,26 +2,29 @@ package service
 
 import (
 	"cirno-im/logger"
+	"cirno-im/naming"
 	"cirno-im/wire/rpc"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/protobuf/proto"
 	"time"
 )
 
 type Group interface {
-	Create(app string, req *rpc.CreateGroupReq) (*rpc.CreateGroupResp, error)
-	Members(app string, req *rpc.GroupMembersReq) (*rpc.GroupMembersResp, error)
-	Join(app string, req *rpc.JoinGroupReq) error
-	Quit(app string, req *rpc.QuitGroupReq) error
-	Detail(app string, req *rpc.GetGroupReq) (*rpc.GetGroupResp, error)
+	Create(ctx context.Context, app string, req *rpc.CreateGroupReq) (*rpc.CreateGroupResp, error)
+	Members(ctx context.Context, app string, req *rpc.GroupMembersReq) (*rpc.GroupMembersResp, error)
+	Join(ctx context.Context, app string, req *rpc.JoinGroupReq) error
+	Quit(ctx context.Context, app string, req *rpc.QuitGroupReq) error
+	Detail(ctx context.Context, app string, req *rpc.GetGroupReq) (*rpc.GetGroupResp, error)
 }
 
 type GroupHttp struct {
 	url string
 	cli *resty.Client
-	srv *resty.SRVRecord
 }
 
 func NewGroupService(url string) Group {
@@ -37,27 +40,20 @@ func NewGroupService(url string) Group {
 	}
 }
 
-func NewGroupServiceWithSRV(scheme string, srv *resty.SRVRecord) Group {
-	cli := resty.New().SetRetryCount(3).SetTimeout(time.Second * 5)
-	cli.SetHeaders(map[string]string{
-		"Content-Type": "application/x-protobuf",
-		"Accept":       "application/x-protobuf",
-	})
-	cli.SetScheme("http")
-	return &GroupHttp{
-		url: "",
-		cli: cli,
-		srv: srv,
-	}
+// NewGroupServiceWithNaming 通过注册中心查找royal服务，ns需要已经按命名空间过滤
+func NewGroupServiceWithNaming(ns naming.Naming) Group {
+	g := NewGroupService("").(*GroupHttp)
+	g.cli.OnBeforeRequest(resolveRoyal(ns))
+	return g
 }
 
-func (g *GroupHttp) Create(app string, req *rpc.CreateGroupReq) (*rpc.CreateGroupResp, error) {
+func (g *GroupHttp) Create(ctx context.Context, app string, req *rpc.CreateGroupReq) (*rpc.CreateGroupResp, error) {
 	path := fmt.Sprintf("%s/api/%s/group", g.url, app)
 	body, err := proto.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
-	response, err := g.Request().SetBody(body).Post(path)
+	response, err := g.Request(ctx).SetBody(body).Post(path)
 	if err != nil {
 		return nil, err
 	}
@@ -73,9 +69,9 @@ func (g *GroupHttp) Create(app string, req *rpc.CreateGroupReq) (*rpc.CreateGrou
 	return &resp, err
 }
 
-func (g *GroupHttp) Members(app string, req *rpc.GroupMembersReq) (*rpc.GroupMembersResp, error) {
+func (g *GroupHttp) Members(ctx context.Context, app string, req *rpc.GroupMembersReq) (*rpc.GroupMembersResp, error) {
 	path := fmt.Sprintf("%s/api/%s/group/member/%s", g.url, app, req.GroupId)
-	response, err := g.Request().Get(path)
+	response, err := g.Request(ctx).Get(path)
 	if err != nil {
 		return nil, err
 	}
@@ -91,10 +87,10 @@ func (g *GroupHttp) Members(app string, req *rpc.GroupMembersReq) (*rpc.GroupMem
 	return &resp, err
 }
 
-func (g *GroupHttp) Join(app string, req *rpc.JoinGroupReq) error {
+func (g *GroupHttp) Join(ctx context.Context, app string, req *rpc.JoinGroupReq) error {
 	path := fmt.Sprintf("%s/api/%s/group/member", g.url, app)
 	body, _ := proto.Marshal(req)
-	response, err := g.Request().SetBody(body).Post(path)
+	response, err := g.Request(ctx).SetBody(body).Post(path)
 	if err != nil {
 		return err
 	}
@@ -104,10 +100,10 @@ func (g *GroupHttp) Join(app string, req *rpc.JoinGroupReq) error {
 	return nil
 }
 
-func (g *GroupHttp) Quit(app string, req *rpc.QuitGroupReq) error {
+func (g *GroupHttp) Quit(ctx context.Context, app string, req *rpc.QuitGroupReq) error {
 	path := fmt.Sprintf("%s/api/%s/group/member", g.url, app)
 	body, _ := proto.Marshal(req)
-	response, err := g.Request().SetBody(body).Delete(path)
+	response, err := g.Request(ctx).SetBody(body).Delete(path)
 	if err != nil {
 		return err
 	}
@@ -117,9 +113,9 @@ func (g *GroupHttp) Quit(app string, req *rpc.QuitGroupReq) error {
 	return nil
 }
 
-func (g *GroupHttp) Detail(app string, req *rpc.GetGroupReq) (*rpc.GetGroupResp, error) {
+func (g *GroupHttp) Detail(ctx context.Context, app string, req *rpc.GetGroupReq) (*rpc.GetGroupResp, error) {
 	path := fmt.Sprintf("%s/api/%s/group/%s", g.url, app, req.GroupId)
-	response, err := g.Request().Get(path)
+	response, err := g.Request(ctx).Get(path)
 	if err != nil {
 		return nil, err
 	}
@@ -132,9 +128,9 @@ func (g *GroupHttp) Detail(app string, req *rpc.GetGroupReq) (*rpc.GetGroupResp,
 	return &resp, nil
 }
 
-func (g *GroupHttp) Request() *resty.Request {
-	if g.srv == nil {
-		return g.cli.R()
-	}
-	return g.cli.R().SetSRV(g.srv)
+func (g *GroupHttp) Request(ctx context.Context) *resty.Request {
+	req := g.cli.R().SetContext(ctx)
+	// 将链路上下文注入到请求头中
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req
 }
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"cirno-im/logger"
+	"cirno-im/naming"
+	"cirno-im/wire/rpc"
+	"context"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/proto"
+	"time"
+)
+
+// Key 端到端加密的公钥目录，由royal服务保存
+type Key interface {
+	Upload(ctx context.Context, app string, req *rpc.KeyBundle) error
+	Fetch(ctx context.Context, app string, req *rpc.FetchKeyReq) (*rpc.FetchKeyResp, error)
+}
+
+type KeyHttp struct {
+	url string
+	cli *resty.Client
+}
+
+func NewKeyService(url string) Key {
+	cli := resty.New().SetRetryCount(3).SetTimeout(time.Second * 5)
+	cli.SetHeaders(map[string]string{
+		"Content-Type": "application/x-protobuf",
+		"Accept":       "application/x-protobuf",
+	})
+	cli.SetScheme("http")
+	return &KeyHttp{
+		url: url,
+		cli: cli,
+	}
+}
+
+// NewKeyServiceWithNaming 通过注册中心查找royal服务，ns需要已经按命名空间过滤
+func NewKeyServiceWithNaming(ns naming.Naming) Key {
+	k := NewKeyService("").(*KeyHttp)
+	k.cli.OnBeforeRequest(resolveRoyal(ns))
+	return k
+}
+
+func (k *KeyHttp) Upload(ctx context.Context, app string, req *rpc.KeyBundle) error {
+	path := fmt.Sprintf("%s/api/%s/key", k.url, app)
+	body, _ := proto.Marshal(req)
+	response, err := k.Request(ctx).SetBody(body).Post(path)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode() != 200 {
+		return fmt.Errorf("KeyHttp.Upload response.StatusCode() = %d, want 200", response.StatusCode())
+	}
+	return nil
+}
+
+func (k *KeyHttp) Fetch(ctx context.Context, app string, req *rpc.FetchKeyReq) (*rpc.FetchKeyResp, error) {
+	path := fmt.Sprintf("%s/api/%s/key/fetch", k.url, app)
+	body, _ := proto.Marshal(req)
+	response, err := k.Request(ctx).SetBody(body).Post(path)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode() != 200 {
+		return nil, fmt.Errorf("KeyHttp.Fetch response.StatusCode() = %d, want 200", response.StatusCode())
+	}
+	var resp rpc.FetchKeyResp
+	if err = proto.Unmarshal(response.Body(), &resp); err != nil {
+		return nil, err
+	}
+	logger.Debugf("KeyHttp.Fetch %d bundles", len(resp.Bundles))
+	return &resp, nil
+}
+
+func (k *KeyHttp) Request(ctx context.Context) *resty.Request {
+	req := k.cli.R().SetContext(ctx)
+	// 将链路上下文注入到请求头中
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req
+}
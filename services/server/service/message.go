@@ -2,25 +2,28 @@ package service
 
 import (
 	"cirno-im/logger"
+	"cirno-im/naming"
 	"cirno-im/wire/rpc"
+	"context"
 	"fmt"
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/protobuf/proto"
 	"time"
 )
 
 type Message interface {
-	InsertUser(app string, req *rpc.InsertMessageReq) (*rpc.InsertMessageResp, error)
-	InsertGroup(app string, req *rpc.InsertMessageReq) (*rpc.InsertMessageResp, error)
-	SetAck(app string, req *rpc.AckMessageReq) error
-	GetMessageIndex(app string, req *rpc.GetOfflineMessageIndexReq) (*rpc.GetOfflineMessageIndexResp, error)
-	GetMessageContent(app string, req *rpc.GetOfflineMessageContentReq) (*rpc.GetOfflineMessageContentResp, error)
+	InsertUser(ctx context.Context, app string, req *rpc.InsertMessageReq) (*rpc.InsertMessageResp, error)
+	InsertGroup(ctx context.Context, app string, req *rpc.InsertMessageReq) (*rpc.InsertMessageResp, error)
+	SetAck(ctx context.Context, app string, req *rpc.AckMessageReq) error
+	GetMessageIndex(ctx context.Context, app string, req *rpc.GetOfflineMessageIndexReq) (*rpc.GetOfflineMessageIndexResp, error)
+	GetMessageContent(ctx context.Context, app string, req *rpc.GetOfflineMessageContentReq) (*rpc.GetOfflineMessageContentResp, error)
 }
 
 type MessageHttp struct {
 	url string
 	cli *resty.Client
-	srv *resty.SRVRecord
 }
 
 func NewMessageService(url string) Message {
@@ -33,25 +36,19 @@ func NewMessageService(url string) Message {
 	}
 }
 
-func NewMessageServiceWithSRV(scheme string, srv *resty.SRVRecord) Message {
-	cli := resty.New().SetRetryCount(3).SetTimeout(time.Second * 5)
-	cli.SetHeader("Content-Type", "application/x-protobuf")
-	cli.SetHeader("Accept", "application/x-protobuf")
-	cli.SetScheme("http")
-
-	return &MessageHttp{
-		url: "",
-		cli: cli,
-		srv: srv,
-	}
+// NewMessageServiceWithNaming 通过注册中心查找royal服务，ns需要已经按命名空间过滤
+func NewMessageServiceWithNaming(ns naming.Naming) Message {
+	m := NewMessageService("").(*MessageHttp)
+	m.cli.OnBeforeRequest(resolveRoyal(ns))
+	return m
 }
 
-func (m *MessageHttp) InsertUser(app string, req *rpc.InsertMessageReq) (*rpc.InsertMessageResp, error) {
+func (m *MessageHttp) InsertUser(ctx context.Context, app string, req *rpc.InsertMessageReq) (*rpc.InsertMessageResp, error) {
 	path := fmt.Sprintf("%s/api/%s/message/user", m.url, app)
 	t1 := time.Now()
 
 	body, _ := proto.Marshal(req)
-	response, err := m.Request().SetBody(body).Post(path)
+	response, err := m.Request(ctx).SetBody(body).Post(path)
 	if err != nil {
 		return nil, err
 	}
@@ -64,11 +61,11 @@ func (m *MessageHttp) InsertUser(app string, req *rpc.InsertMessageReq) (*rpc.In
 	return &resp, nil
 }
 
-func (m *MessageHttp) InsertGroup(app string, req *rpc.InsertMessageReq) (*rpc.InsertMessageResp, error) {
+func (m *MessageHttp) InsertGroup(ctx context.Context, app string, req *rpc.InsertMessageReq) (*rpc.InsertMessageResp, error) {
 	path := fmt.Sprintf("%s/api/%s/message/group", m.url, app)
 	t1 := time.Now()
 	body, _ := proto.Marshal(req)
-	response, err := m.Request().SetBody(body).Post(path)
+	response, err := m.Request(ctx).SetBody(body).Post(path)
 	if err != nil {
 		return nil, err
 	}
@@ -81,10 +78,10 @@ func (m *MessageHttp) InsertGroup(app string, req *rpc.InsertMessageReq) (*rpc.I
 	return &resp, nil
 }
 
-func (m *MessageHttp) SetAck(app string, req *rpc.AckMessageReq) error {
+func (m *MessageHttp) SetAck(ctx context.Context, app string, req *rpc.AckMessageReq) error {
 	path := fmt.Sprintf("%s/api/%s/message/ack", m.url, app)
 	body, _ := proto.Marshal(req)
-	response, err := m.Request().SetBody(body).Post(path)
+	response, err := m.Request(ctx).SetBody(body).Post(path)
 	if err != nil {
 		return err
 	}
@@ -94,11 +91,11 @@ func (m *MessageHttp) SetAck(app string, req *rpc.AckMessageReq) error {
 	return nil
 }
 
-func (m *MessageHttp) GetMessageIndex(app string, req *rpc.GetOfflineMessageIndexReq) (*rpc.GetOfflineMessageIndexResp, error) {
+func (m *MessageHttp) GetMessageIndex(ctx context.Context, app string, req *rpc.GetOfflineMessageIndexReq) (*rpc.GetOfflineMessageIndexResp, error) {
 	path := fmt.Sprintf("%s/api/%s/offline/index", m.url, app)
 	body, _ := proto.Marshal(req)
 
-	response, err := m.Request().SetBody(body).Post(path)
+	response, err := m.Request(ctx).SetBody(body).Post(path)
 	if err != nil {
 		return nil, err
 	}
@@ -110,10 +107,10 @@ func (m *MessageHttp) GetMessageIndex(app string, req *rpc.GetOfflineMessageInde
 	return &resp, nil
 }
 
-func (m *MessageHttp) GetMessageContent(app string, req *rpc.GetOfflineMessageContentReq) (*rpc.GetOfflineMessageContentResp, error) {
+func (m *MessageHttp) GetMessageContent(ctx context.Context, app string, req *rpc.GetOfflineMessageContentReq) (*rpc.GetOfflineMessageContentResp, error) {
 	path := fmt.Sprintf("%s/api/%s/offline/content", m.url, app)
 	body, _ := proto.Marshal(req)
-	response, err := m.Request().SetBody(body).Post(path)
+	response, err := m.Request(ctx).SetBody(body).Post(path)
 	if err != nil {
 		return nil, err
 	}
@@ -125,9 +122,9 @@ func (m *MessageHttp) GetMessageContent(app string, req *rpc.GetOfflineMessageCo
 	return &resp, nil
 }
 
-func (m *MessageHttp) Request() *resty.Request {
-	if m.srv == nil {
-		return m.cli.R()
-	}
-	return m.cli.R().SetSRV(m.srv)
+func (m *MessageHttp) Request(ctx context.Context) *resty.Request {
+	req := m.cli.R().SetContext(ctx)
+	// 将链路上下文注入到请求头中
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req
 }
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"cirno-im"
+	"cirno-im/constants"
+	"cirno-im/logger"
+	"cirno-im/naming"
+	"cirno-im/wire"
+	"github.com/go-resty/resty/v2"
+)
+
+// royalResolver 缓存注册中心中的royal节点，过期后在下一次请求时刷新
+type royalResolver struct {
+	sync.Mutex
+	ns        naming.Naming
+	ttl       time.Duration
+	srvs      []cim.ServiceRegistration
+	refreshAt time.Time
+}
+
+// services 返回缓存的royal节点，刷新失败时继续使用上一次的结果
+func (r *royalResolver) services() ([]cim.ServiceRegistration, error) {
+	r.Lock()
+	defer r.Unlock()
+	if time.Now().Before(r.refreshAt) {
+		return r.srvs, nil
+	}
+	srvs, err := r.ns.Find(wire.SNService)
+	if err != nil {
+		if r.srvs == nil {
+			return nil, err
+		}
+		logger.WithField("func", "resolveRoyal").Warn(err)
+	} else {
+		r.srvs = srvs
+	}
+	r.refreshAt = time.Now().Add(r.ttl)
+	return r.srvs, nil
+}
+
+// resolveRoyal 每次请求前从缓存的royal节点中随机选择一个作为请求地址，ns需要已经按命名空间过滤
+// DNS SRV查询不能按标签过滤，同一个consul中有多个命名空间时只能通过注册中心查找
+func resolveRoyal(ns naming.Naming) resty.RequestMiddleware {
+	resolver := &royalResolver{ns: ns, ttl: constants.DefaultRoyalRefresh}
+	return func(_ *resty.Client, r *resty.Request) error {
+		if !strings.HasPrefix(r.URL, "/") {
+			return nil
+		}
+		srvs, err := resolver.services()
+		if err != nil {
+			return err
+		}
+		if len(srvs) == 0 {
+			return fmt.Errorf("no %s service found", wire.SNService)
+		}
+		srv := srvs[rand.Intn(len(srvs))]
+		r.URL = fmt.Sprintf("http://%s:%d%s", srv.PublicAddress(), srv.PublicPort(), r.URL)
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"cirno-im"
+	"cirno-im/naming"
+	"cirno-im/wire"
+	"cirno-im/wire/rpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+type staticNaming struct {
+	naming.Naming
+	services []cim.ServiceRegistration
+	finds    int
+	err      error
+}
+
+func (n *staticNaming) Find(name string, tags ...string) ([]cim.ServiceRegistration, error) {
+	n.finds++
+	if n.err != nil {
+		return nil, n.err
+	}
+	return n.services, nil
+}
+
+func royalService(t *testing.T, id, namespace string, handler http.HandlerFunc) cim.ServiceRegistration {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return &naming.DefaultService{Id: id, Name: wire.SNService, Namespace: namespace, Address: host, Port: p}
+}
+
+func TestResolveRoyal(t *testing.T) {
+	staging := royalService(t, "royal01", "staging", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/cim/key/fetch", r.URL.Path)
+		body, _ := proto.Marshal(&rpc.FetchKeyResp{})
+		_, _ = w.Write(body)
+	})
+	loadtest := royalService(t, "royal02", "loadtest", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request sent to another namespace")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	static := &staticNaming{services: []cim.ServiceRegistration{staging, loadtest}}
+	ns := naming.WithNamespace(static, "staging")
+
+	keys := NewKeyServiceWithNaming(ns)
+	for i := 0; i < 5; i++ {
+		_, err := keys.Fetch(context.Background(), "cim", &rpc.FetchKeyReq{Accounts: []string{"test1"}})
+		assert.Nil(t, err)
+	}
+	// 节点列表被缓存，不会每次请求都查询注册中心
+	assert.Equal(t, 1, static.finds)
+
+	_, err := NewKeyServiceWithNaming(naming.WithNamespace(&staticNaming{}, "staging")).
+		Fetch(context.Background(), "cim", &rpc.FetchKeyReq{Accounts: []string{"test1"}})
+	assert.NotNil(t, err)
+}
+
+func TestRoyalResolverRefresh(t *testing.T) {
+	static := &staticNaming{services: []cim.ServiceRegistration{&naming.DefaultService{Id: "royal01"}}}
+	r := &royalResolver{ns: static}
+	srvs, err := r.services()
+	assert.Nil(t, err)
+	assert.Len(t, srvs, 1)
+
+	// 缓存过期后重新查询，查询失败时使用上一次的结果
+	static.err = errors.New("consul is unavailable")
+	srvs, err = r.services()
+	assert.Nil(t, err)
+	assert.Len(t, srvs, 1)
+	assert.Equal(t, 2, static.finds)
+
+	_, err = (&royalResolver{ns: static}).services()
+	assert.NotNil(t, err)
+}
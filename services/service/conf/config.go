@@ -31,6 +31,10 @@ type Config struct {
 	BaseDb        string
 	MessageDb     string
 	LogLevel      string `default:"INFO"`
+	// Namespace 服务所在的命名空间
+	Namespace string
+	// AdminToken 管理接口(token密钥、撤销)的token，为空时不开放
+	AdminToken string
 }
 
 func (c Config) String() string {
@@ -31,6 +31,8 @@ type MessageContent struct {
 	Body     string `gorm:"size:5000;not null"`
 	Extra    string `gorm:"size:500"`
 	SendTime int64  `gorm:"index"`
+	// Encrypted Body是客户端端到端加密的密文
+	Encrypted bool `gorm:"default:false"`
 }
 
 type User struct {
@@ -59,3 +61,22 @@ type GroupMember struct {
 	Group   string `gorm:"uniqueIndex:uni_gp_acc;index;size:30"`
 	Alias   string `gorm:"size:30"`
 }
+
+// KeyBundle 用户端到端加密的公钥，每个账号只保留最新的一份
+type KeyBundle struct {
+	Model
+	App          string `gorm:"uniqueIndex:uni_app_acc;size:30"`
+	Account      string `gorm:"uniqueIndex:uni_app_acc;size:60"`
+	IdentityKey  []byte `gorm:"size:64;not null"`
+	SignedPreKey []byte `gorm:"size:64;not null"`
+	Signature    []byte `gorm:"size:128"`
+}
+
+// TokenKey 验证登录token的公钥，删除后网关在宽限期内仍然接受旧密钥签发的token
+type TokenKey struct {
+	Model
+	App       string `gorm:"uniqueIndex:uni_app_kid;size:30"`
+	Kid       string `gorm:"uniqueIndex:uni_app_kid;size:60"`
+	Alg       string `gorm:"size:10;not null"`
+	PublicKey string `gorm:"size:1024"`
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	cim "cirno-im"
+	"cirno-im/services/server/handler"
+	logicservice "cirno-im/services/server/service"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"cirno-im/wire/rpc"
+	"github.com/kataras/iris/v12"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+type respDispatcher struct {
+	resps []*pkt.LogicPkt
+}
+
+func (d *respDispatcher) Push(gateway string, channels []string, p *pkt.LogicPkt) error {
+	d.resps = append(d.resps, p)
+	return nil
+}
+
+func TestEndToEndEncryption(t *testing.T) {
+	var (
+		stored   rpc.InsertMessageReq
+		uploaded rpc.KeyBundle
+	)
+	app := iris.New()
+	app.UseRouter(setAllowedResponses)
+	app.Post("/api/{app}/message/user", func(ctx iris.Context) {
+		_ = ctx.ReadBody(&stored)
+		_, _ = ctx.Negotiate(&rpc.InsertMessageResp{MessageId: 1})
+	})
+	app.Post("/api/{app}/key", func(ctx iris.Context) {
+		_ = ctx.ReadBody(&uploaded)
+	})
+	app.Post("/api/{app}/key/fetch", func(ctx iris.Context) {
+		_, _ = ctx.Negotiate(&rpc.FetchKeyResp{Bundles: []*rpc.KeyBundle{proto.Clone(&uploaded).(*rpc.KeyBundle)}})
+	})
+	assert.Nil(t, app.Build())
+	royal := httptest.NewServer(app)
+	defer royal.Close()
+
+	r := cim.NewRouter()
+	keyHandler := handler.NewKeyHandler(logicservice.NewKeyService(royal.URL))
+	r.Handle(wire.CommandKeyUpload, keyHandler.DoUpload)
+	r.Handle(wire.CommandKeyFetch, keyHandler.DoFetch)
+	chatHandler := handler.NewChatHandler(logicservice.NewMessageService(royal.URL), logicservice.NewGroupService(royal.URL))
+	r.Handle(wire.CommandChatUserTalk, chatHandler.DoUserTalk)
+	r.Handle(wire.CommandChatGroupTalk, chatHandler.DoGroupTalk)
+	session := &pkt.Session{ChannelID: "ch1", App: "cim", Account: "test1"}
+
+	// 只能上传自己的公钥
+	d := &respDispatcher{}
+	err := r.Serve(pkt.New(wire.CommandKeyUpload).WriteBody(&pkt.KeyBundle{
+		Account:      "test2",
+		IdentityKey:  []byte{1, 2, 3},
+		SignedPreKey: []byte{4, 5, 6},
+	}), d, &nopStorage{}, session)
+	assert.Nil(t, err)
+	assert.Equal(t, pkt.Status_Success, d.resps[0].Status)
+	assert.Equal(t, "test1", uploaded.Account)
+
+	d = &respDispatcher{}
+	_ = r.Serve(pkt.New(wire.CommandKeyFetch).WriteBody(&pkt.KeyFetchRequest{Accounts: []string{"test1"}}), d, &nopStorage{}, session)
+	var keys pkt.KeyFetchResponse
+	assert.Nil(t, d.resps[0].ReadBody(&keys))
+	assert.Equal(t, []byte{1, 2, 3}, keys.Bundles[0].IdentityKey)
+
+	// 密文原样保存
+	d = &respDispatcher{}
+	_ = r.Serve(pkt.New(wire.CommandChatUserTalk, pkt.WithDest("test2")).WriteBody(&pkt.MessageRequest{
+		Type:      wire.MessageTypeText,
+		Body:      "c2VjcmV0",
+		Encrypted: true,
+	}), d, &nopStorage{}, session)
+	assert.Equal(t, pkt.Status_Success, d.resps[0].Status)
+	assert.Equal(t, "c2VjcmV0", stored.Message.Body)
+	assert.True(t, stored.Message.Encrypted)
+
+	d = &respDispatcher{}
+	_ = r.Serve(pkt.New(wire.CommandChatGroupTalk, pkt.WithDest("group1")).WriteBody(&pkt.MessageRequest{
+		Body:      "c2VjcmV0",
+		Encrypted: true,
+	}), d, &nopStorage{}, session)
+	assert.Equal(t, pkt.Status_InvalidPacketBody, d.resps[0].Status)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+
+	"cirno-im/services/service/database"
+	"cirno-im/wire"
+	"cirno-im/wire/rpc"
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm/clause"
+)
+
+// KeyUpload 保存用户的公钥，已经存在时覆盖
+func (h *ServiceHandler) KeyUpload(c iris.Context) {
+	app := c.Params().Get("app")
+	var req rpc.KeyBundle
+	if err := c.ReadBody(&req); err != nil {
+		c.StopWithError(iris.StatusBadRequest, err)
+		return
+	}
+	if req.Account == "" || len(req.IdentityKey) == 0 || len(req.SignedPreKey) == 0 {
+		c.StopWithError(iris.StatusBadRequest, errors.New("account, identity_key and signed_pre_key are required"))
+		return
+	}
+	bundle := &database.KeyBundle{
+		Model: database.Model{
+			ID: h.IdGen.Next().Int64(),
+		},
+		App:          app,
+		Account:      req.Account,
+		IdentityKey:  req.IdentityKey,
+		SignedPreKey: req.SignedPreKey,
+		Signature:    req.Signature,
+	}
+	err := h.BaseDb.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "app"}, {Name: "account"}},
+		DoUpdates: clause.AssignmentColumns([]string{"identity_key", "signed_pre_key", "signature", "updated_at", "deleted_at"}),
+	}).Create(bundle).Error
+	if err != nil {
+		c.StopWithError(iris.StatusInternalServerError, err)
+		return
+	}
+}
+
+// KeyFetch 批量获取用户的公钥，没有上传过的账号不在结果中
+func (h *ServiceHandler) KeyFetch(c iris.Context) {
+	app := c.Params().Get("app")
+	var req rpc.FetchKeyReq
+	if err := c.ReadBody(&req); err != nil {
+		c.StopWithError(iris.StatusBadRequest, err)
+		return
+	}
+	if len(req.Accounts) > wire.KeyMaxCountPerFetch {
+		c.StopWithText(iris.StatusBadRequest, "too many accounts")
+		return
+	}
+	var bundles []database.KeyBundle
+	err := h.BaseDb.Where("app = ? AND account IN ?", app, req.Accounts).Find(&bundles).Error
+	if err != nil {
+		c.StopWithError(iris.StatusInternalServerError, err)
+		return
+	}
+	list := make([]*rpc.KeyBundle, len(bundles))
+	for i, b := range bundles {
+		list[i] = &rpc.KeyBundle{
+			Account:      b.Account,
+			IdentityKey:  b.IdentityKey,
+			SignedPreKey: b.SignedPreKey,
+			Signature:    b.Signature,
+			UpdatedAt:    b.UpdatedAt.Unix(),
+		}
+	}
+	if _, err = c.Negotiate(&rpc.FetchKeyResp{
+		Bundles: list,
+	}); err != nil {
+		c.StopWithError(iris.StatusInternalServerError, err)
+		return
+	}
+}
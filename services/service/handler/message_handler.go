@@ -25,11 +25,12 @@ func (h *ServiceHandler) InsertUserMessage(c iris.Context) {
 	}
 	messageId := h.IdGen.Next().Int64()
 	messageContent := database.MessageContent{
-		ID:       messageId,
-		Type:     byte(req.Message.Type),
-		Body:     req.Message.Body,
-		Extra:    req.Message.Extra,
-		SendTime: req.SendTime,
+		ID:        messageId,
+		Type:      byte(req.Message.Type),
+		Body:      req.Message.Body,
+		Extra:     req.Message.Extra,
+		SendTime:  req.SendTime,
+		Encrypted: req.Message.Encrypted,
 	}
 	//扩散写
 	idxs := make([]database.MessageIndex, 2)
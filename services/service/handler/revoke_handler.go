@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"time"
+
+	"cirno-im/storage"
+	"cirno-im/wire"
+	"cirno-im/wire/token"
+	"github.com/kataras/iris/v12"
+)
+
+// RevokeToken 撤销一个token，持有它的连接会被网关踢下线
+func (h *ServiceHandler) RevokeToken(c iris.Context) {
+	h.revoke(c, &token.Revocation{
+		App:     c.Params().Get("app"),
+		TokenID: c.Params().Get("jti"),
+	})
+}
+
+// RevokeAccount 撤销账号当前所有的token，并把它所有在线的连接踢下线
+func (h *ServiceHandler) RevokeAccount(c iris.Context) {
+	h.revoke(c, &token.Revocation{
+		App:     c.Params().Get("app"),
+		Account: c.Params().Get("account"),
+	})
+}
+
+func (h *ServiceHandler) revoke(c iris.Context, r *token.Revocation) {
+	r.At = time.Now().Unix()
+	err := storage.NewRevokeList(h.Cache).Revoke(r, wire.TokenRevokeExpiresIn)
+	if err != nil {
+		c.StopWithError(iris.StatusInternalServerError, err)
+		return
+	}
+}
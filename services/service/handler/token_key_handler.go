@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+
+	"cirno-im/services/service/database"
+	"cirno-im/wire/rpc"
+	"cirno-im/wire/token"
+	"github.com/kataras/iris/v12"
+)
+
+// TokenKeySave 添加app的一个公钥，app为*时对所有app生效
+// royal只保存和下发RS256、EdDSA的公钥，HS256的密钥只能配置在网关的密钥文件中；kid不能重复使用，轮换时使用新的kid
+func (h *ServiceHandler) TokenKeySave(c iris.Context) {
+	app := c.Params().Get("app")
+	var req rpc.TokenKey
+	if err := c.ReadBody(&req); err != nil {
+		c.StopWithError(iris.StatusBadRequest, err)
+		return
+	}
+	if req.Alg == token.AlgHS256 || req.Secret != "" {
+		c.StopWithError(iris.StatusBadRequest, errors.New("only public keys can be saved, configure HS256 secrets in the gateway key file"))
+		return
+	}
+	// 先校验密钥能被网关解析
+	store := token.NewKeyStore("", 0)
+	err := store.Update([]token.Key{{App: app, ID: req.Kid, Alg: req.Alg, PublicKey: req.PublicKey}})
+	if err != nil {
+		c.StopWithError(iris.StatusBadRequest, err)
+		return
+	}
+	// 删除过的kid也不能再用，网关在宽限期内仍然会用旧的密钥验证
+	var count int64
+	if err = h.BaseDb.Unscoped().Model(&database.TokenKey{}).Where("app = ? AND kid = ?", app, req.Kid).Count(&count).Error; err != nil {
+		c.StopWithError(iris.StatusInternalServerError, err)
+		return
+	}
+	if count > 0 {
+		c.StopWithError(iris.StatusConflict, errors.New("token key id is already used"))
+		return
+	}
+	key := &database.TokenKey{
+		Model: database.Model{
+			ID: h.IdGen.Next().Int64(),
+		},
+		App:       app,
+		Kid:       req.Kid,
+		Alg:       req.Alg,
+		PublicKey: req.PublicKey,
+	}
+	if err = h.BaseDb.Create(key).Error; err != nil {
+		c.StopWithError(iris.StatusInternalServerError, err)
+		return
+	}
+}
+
+// TokenKeyDelete 删除app的一个密钥，用于轮换
+func (h *ServiceHandler) TokenKeyDelete(c iris.Context) {
+	app := c.Params().Get("app")
+	kid := c.Params().Get("kid")
+	result := h.BaseDb.Where("app = ? AND kid = ?", app, kid).Delete(&database.TokenKey{})
+	if result.Error != nil {
+		c.StopWithError(iris.StatusInternalServerError, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.StopWithError(iris.StatusNotFound, errors.New("token key not found"))
+		return
+	}
+}
+
+// TokenKeyList 返回所有app的公钥，供网关定期拉取
+func (h *ServiceHandler) TokenKeyList(c iris.Context) {
+	var keys []database.TokenKey
+	if err := h.BaseDb.Find(&keys).Error; err != nil {
+		c.StopWithError(iris.StatusInternalServerError, err)
+		return
+	}
+	list := make([]*rpc.TokenKey, len(keys))
+	for i, k := range keys {
+		list[i] = &rpc.TokenKey{
+			App:       k.App,
+			Kid:       k.Kid,
+			Alg:       k.Alg,
+			PublicKey: k.PublicKey,
+		}
+	}
+	if _, err := c.Negotiate(&rpc.ListTokenKeyResp{
+		Keys: list,
+	}); err != nil {
+		c.StopWithError(iris.StatusInternalServerError, err)
+		return
+	}
+}
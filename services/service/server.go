@@ -1,21 +1,33 @@
 package service
 
 import (
+	"cirno-im/container"
 	"cirno-im/logger"
 	"cirno-im/naming"
 	"cirno-im/naming/consul"
+	"cirno-im/naming/factory"
 	"cirno-im/services/service/conf"
 	"cirno-im/services/service/database"
 	"cirno-im/services/service/handler"
+	"cirno-im/trace"
 	"cirno-im/wire"
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"github.com/kataras/iris/v12"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"hash/crc32"
+	"net/http"
+	"strings"
 )
 
 type ServerStartOptions struct {
+	naming factory.Options
 	config string
 }
 
@@ -27,6 +39,7 @@ func NewServerStartCmd(ctx context.Context, version string) *cobra.Command {
 		RunE:  func(cmd *cobra.Command, args []string) error { return RunServerStart(ctx, opts, version) },
 	}
 	cmd.PersistentFlags().StringVarP(&opts.config, "config", "c", "conf.yaml", "config file")
+	factory.AddFlags(cmd.PersistentFlags(), &opts.naming)
 	return cmd
 }
 
@@ -47,7 +60,7 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 	if err != nil {
 		return err
 	}
-	_ = db.AutoMigrate(&database.Group{}, &database.GroupMember{})
+	_ = db.AutoMigrate(&database.Group{}, &database.GroupMember{}, &database.KeyBundle{}, &database.TokenKey{})
 
 	messageDb, err := database.InitMysqlDB(config.MessageDb)
 	if err != nil {
@@ -68,17 +81,19 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 		return err
 	}
 
-	ns, err := consul.NewNaming(config.ConsulURL)
+	ns, err := factory.New(opts.naming, config.ConsulURL)
 	if err != nil {
 		return err
 	}
+	defer factory.Close(ns)
 
 	if err = ns.Register(&naming.DefaultService{
-		Name:     wire.SNService, // service name
-		Address:  config.PublicAddress,
-		Port:     config.PublicPort,
-		Protocol: "http",
-		Tags:     config.Tags,
+		Name:      wire.SNService, // service name
+		Namespace: config.Namespace,
+		Address:   config.PublicAddress,
+		Port:      config.PublicPort,
+		Protocol:  "http",
+		Tags:      config.Tags,
 		Meta: map[string]string{
 			consul.KeyHealthURL: fmt.Sprintf("http://%s:%d/health", config.PublicAddress, config.PublicPort),
 		},
@@ -101,13 +116,17 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions, version strin
 	ac := conf.MakeAccessLog()
 	defer ac.Close()
 
-	app := newApp(&serviceHandler)
+	if config.AdminToken == "" {
+		logger.Warn("AdminToken is empty, token key and revocation apis are disabled")
+	}
+	app := newApp(&serviceHandler, config.AdminToken)
 	app.UseRouter(ac.Handler)
+	app.UseRouter(tracing)
 	app.UseRouter(setAllowedResponses)
 	return app.Listen(config.Listen, iris.WithOptimizations)
 }
 
-func newApp(serviceHandler *handler.ServiceHandler) *iris.Application {
+func newApp(serviceHandler *handler.ServiceHandler, adminToken string) *iris.Application {
 	app := iris.Default()
 	app.Get("/health", func(ctx iris.Context) {
 		_, _ = ctx.WriteString("ok")
@@ -127,6 +146,26 @@ func newApp(serviceHandler *handler.ServiceHandler) *iris.Application {
 		groupApi.Get("/members/:id", serviceHandler.GroupMembers)
 	}
 
+	keyApi := app.Party("/api/:app/key")
+	{
+		keyApi.Post("", serviceHandler.KeyUpload)
+		keyApi.Post("/fetch", serviceHandler.KeyFetch)
+	}
+
+	admin := adminAuth(adminToken)
+	tokenKeyApi := app.Party("/api/:app/token/key", admin)
+	{
+		tokenKeyApi.Post("", serviceHandler.TokenKeySave)
+		tokenKeyApi.Delete("/:kid", serviceHandler.TokenKeyDelete)
+	}
+	app.Get("/api/token/keys", admin, serviceHandler.TokenKeyList)
+
+	revokeApi := app.Party("/api/:app/revoke", admin)
+	{
+		revokeApi.Post("/token/:jti", serviceHandler.RevokeToken)
+		revokeApi.Post("/account/:account", serviceHandler.RevokeAccount)
+	}
+
 	offlineApi := app.Party("/api/:app/offline")
 	{
 		offlineApi.Use(iris.Compression)
@@ -136,12 +175,51 @@ func newApp(serviceHandler *handler.ServiceHandler) *iris.Application {
 	return app
 }
 
+// adminAuth 校验管理接口的token，与容器的管理接口使用相同的请求头，token为空时不开放
+func adminAuth(token string) iris.Handler {
+	return func(ctx iris.Context) {
+		if token == "" {
+			ctx.StopWithStatus(iris.StatusNotFound)
+			return
+		}
+		got := ctx.GetHeader(container.AdminTokenHeader)
+		if got == "" {
+			got = strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			ctx.StopWithStatus(iris.StatusUnauthorized)
+			return
+		}
+		ctx.Next()
+	}
+}
+
 func setAllowedResponses(ctx iris.Context) {
 	ctx.Negotiation().JSON().Protobuf().MsgPack()
 	ctx.Negotiation().Accept.JSON()
 	ctx.Next()
 }
 
+// tracing 延续逻辑服务在请求头中传递过来的span
+func tracing(ctx iris.Context) {
+	r := ctx.Request()
+	spanCtx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	spanCtx, span := trace.Tracer().Start(spanCtx, fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+		oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.target", r.URL.Path),
+		))
+	defer span.End()
+	ctx.ResetRequest(r.WithContext(spanCtx))
+
+	ctx.Next()
+	span.SetAttributes(attribute.Int("http.status_code", ctx.GetStatusCode()))
+	if ctx.GetStatusCode() >= iris.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(ctx.GetStatusCode()))
+	}
+}
+
 func HashCode(id string) uint32 {
 	hash32 := crc32.NewIEEE()
 	_, _ = hash32.Write([]byte(id))
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cirno-im/container"
+	"github.com/kataras/iris/v12"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	newServer := func(token string) *httptest.Server {
+		app := iris.New()
+		app.Get("/api/token/keys", adminAuth(token), func(ctx iris.Context) {
+			_, _ = ctx.WriteString("ok")
+		})
+		assert.Nil(t, app.Build())
+		return httptest.NewServer(app)
+	}
+	get := func(url, header, value string) int {
+		req, _ := http.NewRequest(http.MethodGet, url+"/api/token/keys", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	royal := newServer("admin")
+	defer royal.Close()
+	assert.Equal(t, http.StatusUnauthorized, get(royal.URL, "", ""))
+	assert.Equal(t, http.StatusUnauthorized, get(royal.URL, container.AdminTokenHeader, "wrong"))
+	assert.Equal(t, http.StatusOK, get(royal.URL, container.AdminTokenHeader, "admin"))
+	assert.Equal(t, http.StatusOK, get(royal.URL, "Authorization", "Bearer admin"))
+
+	// 没有配置token时不开放
+	closed := newServer("")
+	defer closed.Close()
+	assert.Equal(t, http.StatusNotFound, get(closed.URL, container.AdminTokenHeader, ""))
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	cim "cirno-im"
+	logicservice "cirno-im/services/server/service"
+	"cirno-im/trace"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"cirno-im/wire/rpc"
+	"github.com/kataras/iris/v12"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type nopDispatcher struct{}
+
+func (d *nopDispatcher) Push(gateway string, channels []string, p *pkt.LogicPkt) error { return nil }
+
+type nopStorage struct{}
+
+func (s *nopStorage) Add(session *pkt.Session) error                { return nil }
+func (s *nopStorage) Delete(account string, channelID string) error { return nil }
+func (s *nopStorage) Get(channelID string) (*pkt.Session, error)    { return nil, cim.ErrSessionNil }
+func (s *nopStorage) GetLocations(account ...string) ([]*cim.Location, error) {
+	return nil, cim.ErrSessionNil
+}
+func (s *nopStorage) GetLocation(account string, device string) (*cim.Location, error) {
+	return nil, cim.ErrSessionNil
+}
+
+func TestTracePropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	// royal service
+	app := iris.New()
+	app.UseRouter(tracing)
+	app.Post("/api/{app}/message/ack", func(ctx iris.Context) {
+		ctx.StatusCode(iris.StatusOK)
+	})
+	assert.Nil(t, app.Build())
+	royal := httptest.NewServer(app)
+	defer royal.Close()
+
+	// logic server
+	messageService := logicservice.NewMessageService(royal.URL)
+	r := cim.NewRouter()
+	r.Handle(wire.CommandChatTalkAck, func(ctx cim.Context) {
+		err := messageService.SetAck(ctx.Context(), ctx.Session().GetApp(), &rpc.AckMessageReq{
+			Account:   ctx.Session().GetAccount(),
+			MessageId: 1,
+		})
+		assert.Nil(t, err)
+	})
+
+	// gateway
+	packet := pkt.New(wire.CommandChatTalkAck, pkt.WithChannel("ch1"))
+	ctx, span := trace.Tracer().Start(context.Background(), packet.Command)
+	trace.Inject(ctx, packet)
+	span.End()
+
+	err := r.Serve(packet, &nopDispatcher{}, &nopStorage{}, &pkt.Session{ChannelID: "ch1", App: "cim", Account: "test1"})
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 3, len(spans))
+	var gateway, logic, royalSpan tracetest.SpanStub
+	for _, s := range spans {
+		switch {
+		case !s.Parent.IsValid():
+			gateway = s
+		case s.SpanKind == oteltrace.SpanKindServer && s.Name == wire.CommandChatTalkAck:
+			logic = s
+		default:
+			royalSpan = s
+		}
+	}
+	// gateway -> logic server -> royal service
+	assert.Equal(t, gateway.SpanContext.TraceID(), royalSpan.SpanContext.TraceID())
+	assert.Equal(t, gateway.SpanContext.SpanID(), logic.Parent.SpanID())
+	assert.Equal(t, logic.SpanContext.SpanID(), royalSpan.Parent.SpanID())
+	assert.Equal(t, "POST /api/cim/message/ack", royalSpan.Name)
+}
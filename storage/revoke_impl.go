@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cirno-im/logger"
+	"cirno-im/wire/token"
+	"github.com/go-redis/redis/v7"
+)
+
+// RevokeChannel 广播撤销消息的redis频道
+const RevokeChannel = "cim:revoke"
+
+// RevokeList 保存在redis中的token撤销列表
+type RevokeList struct {
+	cli *redis.Client
+}
+
+func NewRevokeList(cli *redis.Client) *RevokeList {
+	return &RevokeList{cli: cli}
+}
+
+// Revoke 写入撤销列表并广播给所有网关，expiresIn为撤销记录保留的时长
+func (l *RevokeList) Revoke(r *token.Revocation, expiresIn time.Duration) error {
+	key := KeyRevokedAccount(r.App, r.Account)
+	if r.TokenID != "" {
+		key = KeyRevokedToken(r.App, r.TokenID)
+	}
+	if err := l.cli.Set(key, r.At, expiresIn).Err(); err != nil {
+		return err
+	}
+	buf, _ := json.Marshal(r)
+	return l.cli.Publish(RevokeChannel, buf).Err()
+}
+
+// Check 返回token.ErrRevoked表示token已经被撤销，没有iat的token返回token.ErrMissingIat
+func (l *RevokeList) Check(t *token.Token) error {
+	if t.Iat == 0 {
+		return token.ErrMissingIat
+	}
+	keys := []string{KeyRevokedAccount(t.App, t.Account)}
+	if t.ID != "" {
+		keys = append(keys, KeyRevokedToken(t.App, t.ID))
+	}
+	values, err := l.cli.MGet(keys...).Result()
+	if err != nil {
+		return err
+	}
+	for i, val := range values {
+		str, ok := val.(string)
+		if !ok {
+			continue
+		}
+		at, _ := strconv.ParseInt(str, 10, 64)
+		r := &token.Revocation{App: t.App, Account: t.Account, At: at}
+		if i > 0 {
+			r.TokenID = t.ID
+		}
+		if r.Revokes(t) {
+			return token.ErrRevoked
+		}
+	}
+	return nil
+}
+
+// Subscribe 订阅撤销消息，直到ctx结束
+func (l *RevokeList) Subscribe(ctx context.Context, handle func(*token.Revocation)) {
+	sub := l.cli.Subscribe(RevokeChannel)
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var r token.Revocation
+			if err := json.Unmarshal([]byte(msg.Payload), &r); err != nil {
+				logger.Warnf("invalid revocation %s: %v", msg.Payload, err)
+				continue
+			}
+			handle(&r)
+		}
+	}
+}
+
+func KeyRevokedToken(app, tokenID string) string {
+	return fmt.Sprintf("revoke:jti:%s:%s", app, tokenID)
+}
+
+func KeyRevokedAccount(app, account string) string {
+	return fmt.Sprintf("revoke:acc:%s:%s", app, account)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cirno-im/wire/token"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRevokeList(t *testing.T) (*RevokeList, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	cli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = cli.Close() })
+	return NewRevokeList(cli), mr
+}
+
+func TestRevokeListCheck(t *testing.T) {
+	l, mr := newRevokeList(t)
+	tk := &token.Token{App: "cim", Account: "test1", ID: "t1", Iat: 100}
+	assert.Nil(t, l.Check(tk))
+
+	assert.Nil(t, l.Revoke(&token.Revocation{App: "cim", TokenID: "t1", At: 50}, time.Hour))
+	assert.Equal(t, token.ErrRevoked, l.Check(tk))
+	// 其它app中相同的jti不受影响
+	assert.Nil(t, l.Check(&token.Token{App: "other", Account: "test1", ID: "t1", Iat: 100}))
+	assert.True(t, mr.Exists(KeyRevokedToken("cim", "t1")))
+
+	// 撤销账号之后签发的token仍然有效
+	assert.Nil(t, l.Revoke(&token.Revocation{App: "cim", Account: "test2", At: 100}, time.Hour))
+	assert.Equal(t, token.ErrRevoked, l.Check(&token.Token{App: "cim", Account: "test2", ID: "t2", Iat: 100}))
+	assert.Nil(t, l.Check(&token.Token{App: "cim", Account: "test2", ID: "t3", Iat: 101}))
+
+	// 撤销记录过期
+	mr.FastForward(time.Hour)
+	assert.Nil(t, l.Check(tk))
+}
+
+func TestRevokedAccountLogin(t *testing.T) {
+	l, _ := newRevokeList(t)
+	now := time.Now().Unix()
+	old := &token.Token{App: "cim", Account: "test1", Iat: now - 10}
+	assert.Nil(t, l.Check(old))
+
+	assert.Nil(t, l.Revoke(&token.Revocation{App: "cim", Account: "test1", At: now}, time.Hour))
+	assert.Equal(t, token.ErrRevoked, l.Check(old))
+	// 撤销之后重新签发的token可以登录
+	assert.Nil(t, l.Check(&token.Token{App: "cim", Account: "test1", Iat: now + 1}))
+	// 没有iat的token无法判断签发时间
+	assert.Equal(t, token.ErrMissingIat, l.Check(&token.Token{App: "cim", Account: "test1"}))
+	assert.Equal(t, token.ErrMissingIat, l.Check(&token.Token{App: "cim", Account: "test2"}))
+}
+
+func TestRevokeListSubscribe(t *testing.T) {
+	l, mr := newRevokeList(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := make(chan *token.Revocation, 1)
+	go l.Subscribe(ctx, func(r *token.Revocation) { got <- r })
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub(RevokeChannel)[RevokeChannel] == 1
+	}, time.Second, time.Millisecond*10)
+
+	assert.Nil(t, l.Revoke(&token.Revocation{App: "cim", TokenID: "t1", At: 50}, time.Hour))
+	select {
+	case r := <-got:
+		assert.Equal(t, "cim", r.App)
+		assert.Equal(t, "t1", r.TokenID)
+		assert.Equal(t, int64(50), r.At)
+	case <-time.After(time.Second):
+		t.Fatal("revocation is not received")
+	}
+}
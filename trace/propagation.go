@@ -0,0 +1,56 @@
+package trace
+
+import (
+	"context"
+
+	"cirno-im/wire/pkt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName 链路追踪中使用的tracer名称
+const InstrumentationName = "cirno-im"
+
+// Tracer 返回全局TracerProvider中的tracer
+func Tracer() oteltrace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// PacketCarrier 将链路上下文保存在LogicPkt的Meta中
+type PacketCarrier struct {
+	Packet *pkt.LogicPkt
+}
+
+var _ propagation.TextMapCarrier = PacketCarrier{}
+
+// Get returns the value associated with the passed key.
+func (c PacketCarrier) Get(key string) string {
+	val, _ := c.Packet.GetString(key)
+	return val
+}
+
+// Set stores the key-value pair, the existing value of key is replaced.
+func (c PacketCarrier) Set(key string, value string) {
+	c.Packet.DelMeta(key)
+	c.Packet.AddStringMeta(key, value)
+}
+
+// Keys lists the keys stored in this carrier.
+func (c PacketCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.Packet.Meta))
+	for _, m := range c.Packet.Meta {
+		keys = append(keys, m.Key)
+	}
+	return keys
+}
+
+// Inject 把ctx中的链路上下文注入到packet的Meta中
+func Inject(ctx context.Context, packet *pkt.LogicPkt) {
+	otel.GetTextMapPropagator().Inject(ctx, PacketCarrier{Packet: packet})
+}
+
+// Extract 从packet的Meta中读取链路上下文
+func Extract(ctx context.Context, packet *pkt.LogicPkt) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, PacketCarrier{Packet: packet})
+}
@@ -0,0 +1,23 @@
+// Package client 客户端调用逻辑服务的桩代码，client_gen.go根据wire/command中登记的命令生成
+package client
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+)
+
+//go:generate go run cirno-im/wire/command/stubgen -o client_gen.go -pkg client
+
+// Caller 发送一个请求并等待响应，resp为空时不解析响应的body
+type Caller interface {
+	Call(ctx context.Context, command string, req, resp proto.Message) error
+}
+
+type Client struct {
+	Caller
+}
+
+func New(caller Caller) *Client {
+	return &Client{Caller: caller}
+}
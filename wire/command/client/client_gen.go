@@ -0,0 +1,115 @@
+// Code generated by stubgen. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+
+	"cirno-im/wire/pkt"
+)
+
+// ChatGroupCreate chat.group.create，推送的类型为pkt.GroupCreateNotify
+func (c *Client) ChatGroupCreate(ctx context.Context, req *pkt.GroupCreateRequest) (*pkt.GroupCreateResponse, error) {
+	resp := new(pkt.GroupCreateResponse)
+	if err := c.Call(ctx, "chat.group.create", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatGroupDetail chat.group.detail
+func (c *Client) ChatGroupDetail(ctx context.Context, req *pkt.GroupGetReq) (*pkt.GroupGetResp, error) {
+	resp := new(pkt.GroupGetResp)
+	if err := c.Call(ctx, "chat.group.detail", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatGroupJoin chat.group.join
+func (c *Client) ChatGroupJoin(ctx context.Context, req *pkt.GroupJoinReq) error {
+	return c.Call(ctx, "chat.group.join", req, nil)
+}
+
+// ChatGroupQuit chat.group.quit
+func (c *Client) ChatGroupQuit(ctx context.Context, req *pkt.GroupQuitReq) error {
+	return c.Call(ctx, "chat.group.quit", req, nil)
+}
+
+// ChatGroupTalk chat.group.talk，推送的类型为pkt.MessagePush
+func (c *Client) ChatGroupTalk(ctx context.Context, req *pkt.MessageRequest) (*pkt.MessageResponse, error) {
+	resp := new(pkt.MessageResponse)
+	if err := c.Call(ctx, "chat.group.talk", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatKeyFetch chat.key.fetch
+func (c *Client) ChatKeyFetch(ctx context.Context, req *pkt.KeyFetchRequest) (*pkt.KeyFetchResponse, error) {
+	resp := new(pkt.KeyFetchResponse)
+	if err := c.Call(ctx, "chat.key.fetch", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatKeyUpload chat.key.upload
+func (c *Client) ChatKeyUpload(ctx context.Context, req *pkt.KeyBundle) error {
+	return c.Call(ctx, "chat.key.upload", req, nil)
+}
+
+// ChatOfflineContent chat.offline.content
+func (c *Client) ChatOfflineContent(ctx context.Context, req *pkt.MessageContentReq) (*pkt.MessageContentResp, error) {
+	resp := new(pkt.MessageContentResp)
+	if err := c.Call(ctx, "chat.offline.content", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatOfflineIndex chat.offline.index
+func (c *Client) ChatOfflineIndex(ctx context.Context, req *pkt.MessageIndexReq) (*pkt.MessageIndexResp, error) {
+	resp := new(pkt.MessageIndexResp)
+	if err := c.Call(ctx, "chat.offline.index", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatTalkAck chat.talk.ack
+func (c *Client) ChatTalkAck(ctx context.Context, req *pkt.MessageAckRequest) error {
+	return c.Call(ctx, "chat.talk.ack", req, nil)
+}
+
+// ChatUserTalk chat.user.talk，推送的类型为pkt.MessagePush
+func (c *Client) ChatUserTalk(ctx context.Context, req *pkt.MessageRequest) (*pkt.MessageResponse, error) {
+	resp := new(pkt.MessageResponse)
+	if err := c.Call(ctx, "chat.user.talk", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GatewayResend gateway.resend
+func (c *Client) GatewayResend(ctx context.Context, req *pkt.ResendRequest) (*pkt.ResendResponse, error) {
+	resp := new(pkt.ResendResponse)
+	if err := c.Call(ctx, "gateway.resend", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// LoginSignin login.signin
+func (c *Client) LoginSignin(ctx context.Context, req *pkt.LoginRequest) (*pkt.LoginResponse, error) {
+	resp := new(pkt.LoginResponse)
+	if err := c.Call(ctx, "login.signin", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// LoginSignout login.signout
+func (c *Client) LoginSignout(ctx context.Context) error {
+	return c.Call(ctx, "login.signout", nil, nil)
+}
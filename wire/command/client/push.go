@@ -0,0 +1,75 @@
+package client
+
+import (
+	"cirno-im/constants"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+)
+
+// Sender 向网关发送一个已经序列化的packet，cim.Client实现了这个接口
+type Sender interface {
+	Send(payload []byte) error
+}
+
+// PushReceiver 在客户端的读循环中跟踪网关推送的PushSeq，发现缺口时发送gateway.resend，并丢弃重复收到的推送，不是并发安全的
+type PushReceiver struct {
+	sender  Sender
+	tracker pkt.PushTracker
+	// missing 已经请求重发但还没有收到的pushSeq
+	missing map[uint32]struct{}
+}
+
+func NewPushReceiver(sender Sender) *PushReceiver {
+	return &PushReceiver{
+		sender:  sender,
+		missing: make(map[uint32]struct{}),
+	}
+}
+
+// Receive 处理收到的packet，返回false表示重复的推送需要丢弃，err为发送重发请求的错误，此时packet仍然有效
+func (r *PushReceiver) Receive(packet *pkt.LogicPkt) (bool, error) {
+	if packet.Command == wire.CommandGatewayResend && packet.Flag == pkt.Flag_Response && packet.Status == pkt.Status_Success {
+		var resp pkt.ResendResponse
+		if err := packet.ReadBody(&resp); err == nil {
+			r.expire(resp.First)
+		}
+	}
+	seq := packet.PushSeq
+	if seq == 0 {
+		return true, nil
+	}
+	if seq <= r.tracker.Last() {
+		if _, ok := r.missing[seq]; !ok {
+			return false, nil
+		}
+		delete(r.missing, seq)
+		return true, nil
+	}
+	from, to, ok := r.tracker.Track(seq)
+	if !ok {
+		return true, nil
+	}
+	// 网关只保留最近的推送，更早的只能通过离线消息同步
+	if to-from >= constants.DefaultPushHistory {
+		from = to - constants.DefaultPushHistory + 1
+	}
+	for i := from; i <= to; i++ {
+		r.missing[i] = struct{}{}
+	}
+	req := pkt.New(wire.CommandGatewayResend, pkt.WithSequence(wire.Seq.Next())).WriteBody(&pkt.ResendRequest{From: from, To: to})
+	return true, r.sender.Send(pkt.Marshal(req))
+}
+
+// Missing 还没有收到的pushSeq数
+func (r *PushReceiver) Missing() int {
+	return len(r.missing)
+}
+
+// expire 网关已经不再保留first之前的推送，不再等待
+func (r *PushReceiver) expire(first uint32) {
+	for seq := range r.missing {
+		if seq < first {
+			delete(r.missing, seq)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGateway 保留推送记录，收到gateway.resend时把缺失的推送和响应放回客户端的读队列
+type fakeGateway struct {
+	last    uint32
+	history map[uint32]*pkt.LogicPkt
+	inbox   []*pkt.LogicPkt
+	resends []*pkt.ResendRequest
+}
+
+func (g *fakeGateway) push(body string) *pkt.LogicPkt {
+	g.last++
+	p := pkt.New(wire.CommandChatUserTalk).WriteBody(&pkt.MessagePush{Body: body})
+	p.Flag = pkt.Flag_Push
+	p.PushSeq = g.last
+	g.history[g.last] = p
+	return p
+}
+
+func (g *fakeGateway) Send(payload []byte) error {
+	req, err := pkt.MustReadLogicPkt(bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	var body pkt.ResendRequest
+	if err = req.ReadBody(&body); err != nil {
+		return err
+	}
+	g.resends = append(g.resends, &body)
+	for seq := body.From; seq <= body.To; seq++ {
+		g.inbox = append(g.inbox, g.history[seq])
+	}
+	resp := pkt.NewFrom(&req.Header)
+	resp.Flag = pkt.Flag_Response
+	resp.WriteBody(&pkt.ResendResponse{First: 1, Last: g.last})
+	g.last++
+	resp.PushSeq = g.last
+	g.inbox = append(g.inbox, resp)
+	return nil
+}
+
+func TestPushReceiver(t *testing.T) {
+	gw := &fakeGateway{history: make(map[uint32]*pkt.LogicPkt)}
+	r := NewPushReceiver(gw)
+
+	var received []uint32
+	receive := func(p *pkt.LogicPkt) {
+		ok, err := r.Receive(p)
+		assert.Nil(t, err)
+		if ok && p.Flag == pkt.Flag_Push {
+			received = append(received, p.PushSeq)
+		}
+	}
+	receive(gw.push("1"))
+	receive(gw.push("2"))
+	// 3和4在网络中丢失
+	gw.push("3")
+	gw.push("4")
+	receive(gw.push("5"))
+	assert.Len(t, gw.resends, 1)
+	assert.Equal(t, uint32(3), gw.resends[0].From)
+	assert.Equal(t, uint32(4), gw.resends[0].To)
+	assert.Equal(t, 2, r.Missing())
+
+	// 网关重新推送缺失的消息，重复收到的推送被丢弃
+	for len(gw.inbox) > 0 {
+		p := gw.inbox[0]
+		gw.inbox = gw.inbox[1:]
+		receive(p)
+	}
+	receive(gw.history[4])
+	assert.Equal(t, []uint32{1, 2, 5, 3, 4}, received)
+	assert.Equal(t, 0, r.Missing())
+	assert.Len(t, gw.resends, 1)
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"fmt"
+
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"google.golang.org/protobuf/proto"
+)
+
+// Default 登记了wire中定义的所有对外命令，包级别的函数都作用于它
+var Default = NewRegistry()
+
+func init() {
+	// 网关会把登录请求的body替换为pkt.Session再转发给逻辑服务，这里登记的是客户端看到的类型
+	Register(&Schema{Command: wire.CommandLoginSignIn, Request: &pkt.LoginRequest{}, Response: &pkt.LoginResponse{}})
+	Register(&Schema{Command: wire.CommandLoginSignOut})
+	Register(&Schema{Command: wire.CommandLoginKickOut, Push: &pkt.KickOutNotify{}})
+
+	Register(&Schema{Command: wire.CommandChatUserTalk, Request: &pkt.MessageRequest{}, Response: &pkt.MessageResponse{}, Push: &pkt.MessagePush{}, Required: []string{"body"}})
+	Register(&Schema{Command: wire.CommandChatGroupTalk, Request: &pkt.MessageRequest{}, Response: &pkt.MessageResponse{}, Push: &pkt.MessagePush{}, Required: []string{"body"}})
+	Register(&Schema{Command: wire.CommandChatTalkAck, Request: &pkt.MessageAckRequest{}})
+
+	Register(&Schema{Command: wire.CommandOfflineIndex, Request: &pkt.MessageIndexReq{}, Response: &pkt.MessageIndexResp{}})
+	Register(&Schema{Command: wire.CommandOfflineContent, Request: &pkt.MessageContentReq{}, Response: &pkt.MessageContentResp{}})
+
+	Register(&Schema{Command: wire.CommandGroupCreate, Request: &pkt.GroupCreateRequest{}, Response: &pkt.GroupCreateResponse{}, Push: &pkt.GroupCreateNotify{}})
+	Register(&Schema{Command: wire.CommandGroupJoin, Request: &pkt.GroupJoinReq{}})
+	Register(&Schema{Command: wire.CommandGroupQuit, Request: &pkt.GroupQuitReq{}})
+	Register(&Schema{Command: wire.CommandGroupDetail, Request: &pkt.GroupGetReq{}, Response: &pkt.GroupGetResp{}})
+
+	Register(&Schema{Command: wire.CommandKeyUpload, Request: &pkt.KeyBundle{}})
+	Register(&Schema{Command: wire.CommandKeyFetch, Request: &pkt.KeyFetchRequest{}, Response: &pkt.KeyFetchResponse{}})
+
+	Register(&Schema{Command: wire.CommandGatewayResend, Request: &pkt.ResendRequest{}, Response: &pkt.ResendResponse{}})
+}
+
+func Register(s *Schema) {
+	Default.Register(s)
+}
+
+func Lookup(command string) (*Schema, bool) {
+	return Default.Lookup(command)
+}
+
+func All() []*Schema {
+	return Default.All()
+}
+
+func RequestType(command string) (proto.Message, bool) {
+	return Default.RequestType(command)
+}
+
+func Decode(p *pkt.LogicPkt) (proto.Message, error) {
+	return Default.Decode(p)
+}
+
+func Format(p *pkt.LogicPkt) string {
+	return Default.Format(p)
+}
+
+func Describe(p *pkt.LogicPkt) fmt.Stringer {
+	return Default.Describe(p)
+}
@@ -0,0 +1,194 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"cirno-im/wire/pkt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var ErrUnknownCommand = errors.New("command is not registered")
+
+// Schema 一个命令的请求、响应和推送的消息类型，为空表示没有body
+type Schema struct {
+	Command  string
+	Request  proto.Message
+	Response proto.Message
+	Push     proto.Message
+	// Required 请求中必须赋值的字段
+	Required []string
+
+	required []protoreflect.FieldDescriptor
+}
+
+// Registry 命令到消息类型的映射
+type Registry struct {
+	sync.RWMutex
+	schemas map[string]*Schema
+}
+
+func NewRegistry() *Registry {
+	return &Registry{schemas: make(map[string]*Schema)}
+}
+
+// Register 登记一个命令，重复登记或者Required中的字段不存在时panic
+func (r *Registry) Register(s *Schema) {
+	if s.Command == "" {
+		panic("command is empty")
+	}
+	for _, name := range s.Required {
+		if s.Request == nil {
+			panic(fmt.Sprintf("command %s has no request but requires %s", s.Command, name))
+		}
+		desc := s.Request.ProtoReflect().Descriptor()
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = desc.Fields().ByJSONName(name)
+		}
+		if fd == nil {
+			panic(fmt.Sprintf("field %s not found in %s", name, desc.FullName()))
+		}
+		s.required = append(s.required, fd)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.schemas[s.Command]; ok {
+		panic(fmt.Sprintf("command %s has already been registered", s.Command))
+	}
+	r.schemas[s.Command] = s
+}
+
+func (r *Registry) Lookup(command string) (*Schema, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	s, ok := r.schemas[command]
+	return s, ok
+}
+
+// All 按命令排序的所有Schema
+func (r *Registry) All() []*Schema {
+	r.RLock()
+	defer r.RUnlock()
+	list := make([]*Schema, 0, len(r.schemas))
+	for _, s := range r.schemas {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Command < list[j].Command
+	})
+	return list
+}
+
+// RequestType 命令登记的请求类型，供cim.Router注册处理器时检查
+func (r *Registry) RequestType(command string) (proto.Message, bool) {
+	s, ok := r.Lookup(command)
+	if !ok {
+		return nil, false
+	}
+	return s.Request, true
+}
+
+// Decode 按照包的Flag和Status解析body，不修改p，没有body的包返回nil
+func (r *Registry) Decode(p *pkt.LogicPkt) (proto.Message, error) {
+	s, ok := r.Lookup(p.Command)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, p.Command)
+	}
+	var msg proto.Message
+	switch {
+	case p.Flag == pkt.Flag_Push:
+		msg = newMessage(s.Push)
+	case p.Flag == pkt.Flag_Response && p.Status != pkt.Status_Success:
+		msg = &pkt.ErrorResponse{}
+	case p.Flag == pkt.Flag_Response:
+		msg = newMessage(s.Response)
+	default:
+		msg = newMessage(s.Request)
+	}
+	if msg == nil || len(p.Body) == 0 {
+		return msg, nil
+	}
+	body, err := p.PlainBody()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Format 把包解析成便于阅读的字符串，用于日志
+func (r *Registry) Format(p *pkt.LogicPkt) string {
+	msg, err := r.Decode(p)
+	if err != nil || msg == nil {
+		return fmt.Sprintf("%s %s body:%d bytes", p.Command, p.Flag, len(p.Body))
+	}
+	body, _ := json.Marshal(msg)
+	return fmt.Sprintf("%s %s %s%s", p.Command, p.Flag, msg.ProtoReflect().Descriptor().Name(), body)
+}
+
+// Describe 与Format相同，但只在被打印时才解析，用于可能不输出的debug日志
+func (r *Registry) Describe(p *pkt.LogicPkt) fmt.Stringer {
+	return description{r: r, p: p}
+}
+
+type description struct {
+	r *Registry
+	p *pkt.LogicPkt
+}
+
+func (d description) String() string {
+	return d.r.Format(d.p)
+}
+
+// NewRequest 创建一个新的请求消息，命令没有请求body时返回nil
+func (s *Schema) NewRequest() proto.Message {
+	return newMessage(s.Request)
+}
+
+// Validate 校验请求的body能否解析为登记的类型，并且Required中的字段都已赋值
+func (s *Schema) Validate(body []byte) (proto.Message, error) {
+	msg := s.NewRequest()
+	if msg == nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	if err := s.Check(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Check 检查Required中的字段都已赋值
+func (s *Schema) Check(msg proto.Message) error {
+	m := msg.ProtoReflect()
+	for _, fd := range s.required {
+		if !m.Has(fd) {
+			return fmt.Errorf("%s is required", fd.Name())
+		}
+	}
+	return nil
+}
+
+func newMessage(prototype proto.Message) proto.Message {
+	if prototype == nil {
+		return nil
+	}
+	return prototype.ProtoReflect().New().Interface()
+}
+
+// TypeName 消息的类型名，为空时返回空字符串
+func TypeName(msg proto.Message) string {
+	if msg == nil {
+		return ""
+	}
+	return string(msg.ProtoReflect().Descriptor().FullName())
+}
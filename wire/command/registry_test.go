@@ -0,0 +1,79 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	cim "cirno-im"
+	"cirno-im/wire"
+	"cirno-im/wire/pkt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	s, ok := Lookup(wire.CommandChatUserTalk)
+	assert.True(t, ok)
+
+	_, err := s.Validate([]byte(`{"type":1}`))
+	assert.EqualError(t, err, "body is required")
+	_, err = s.Validate([]byte(`not a json`))
+	assert.NotNil(t, err)
+	msg, err := s.Validate([]byte(`{"type":1,"body":"hello"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", msg.(*pkt.MessageRequest).Body)
+
+	assert.Panics(t, func() {
+		Register(&Schema{Command: wire.CommandChatUserTalk})
+	})
+	assert.Panics(t, func() {
+		NewRegistry().Register(&Schema{Command: "a.b", Request: &pkt.MessageRequest{}, Required: []string{"none"}})
+	})
+}
+
+func TestDecode(t *testing.T) {
+	req := pkt.New(wire.CommandChatUserTalk).WriteBody(&pkt.MessageRequest{Body: "hello"})
+	msg, err := Decode(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", msg.(*pkt.MessageRequest).Body)
+
+	push := pkt.New(wire.CommandChatUserTalk).WriteBody(&pkt.MessagePush{Sender: "test1"})
+	push.Flag = pkt.Flag_Push
+	msg, err = Decode(push)
+	assert.Nil(t, err)
+	assert.Equal(t, "test1", msg.(*pkt.MessagePush).Sender)
+
+	resp := pkt.New(wire.CommandChatUserTalk, pkt.WithStatus(pkt.Status_Unauthorized)).WriteBody(&pkt.ErrorResponse{Message: "denied"})
+	resp.Flag = pkt.Flag_Response
+	msg, err = Decode(resp)
+	assert.Nil(t, err)
+	assert.Equal(t, "denied", msg.(*pkt.ErrorResponse).Message)
+	assert.True(t, strings.Contains(Describe(resp).String(), "ErrorResponse"))
+
+	_, err = Decode(pkt.New("unknown.command"))
+	assert.ErrorIs(t, err, ErrUnknownCommand)
+}
+
+func TestHandleRequest(t *testing.T) {
+	r := cim.NewRouter()
+	r.SetRequestTypes(Default)
+	r.HandleRequest(wire.CommandChatUserTalk, &pkt.MessageRequest{}, func(ctx cim.Context) {})
+	assert.Panics(t, func() {
+		r.HandleRequest(wire.CommandChatTalkAck, &pkt.MessageRequest{}, func(ctx cim.Context) {})
+	})
+	assert.Panics(t, func() {
+		r.HandleRequest("unknown.command", &pkt.MessageRequest{}, func(ctx cim.Context) {})
+	})
+}
+
+// 修改登记的命令后需要重新执行go generate ./wire/command/client
+func TestGenerateStubs(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, GenerateStubs(&buf, Default, "client"))
+	generated, err := os.ReadFile("client/client_gen.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(generated), buf.String())
+	// 只有推送的命令不生成调用方法
+	assert.NotContains(t, buf.String(), wire.CommandLoginKickOut)
+}
@@ -0,0 +1,108 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var stubTemplate = template.Must(template.New("stub").Parse(`// Code generated by stubgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+{{range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{range .Stubs}}
+// {{.Method}} {{.Command}}{{if .Push}}，推送的类型为{{.Push}}{{end}}
+func (c *Client) {{.Method}}(ctx context.Context{{if .Request}}, req *{{.Request}}{{end}}) {{if .Response}}(*{{.Response}}, error){{else}}error{{end}} {
+{{- if .Response}}
+	resp := new({{.Response}})
+	if err := c.Call(ctx, "{{.Command}}", {{if .Request}}req{{else}}nil{{end}}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+{{- else}}
+	return c.Call(ctx, "{{.Command}}", {{if .Request}}req{{else}}nil{{end}}, nil)
+{{- end}}
+}
+{{end}}`))
+
+type stub struct {
+	Command  string
+	Method   string
+	Request  string
+	Response string
+	Push     string
+}
+
+// GenerateStubs 为registry中的每个命令生成一个Client的方法，Client需要在pkg中实现Call
+func GenerateStubs(w io.Writer, registry *Registry, pkg string) error {
+	imports := make(map[string]struct{})
+	goType := func(msg proto.Message) string {
+		if msg == nil {
+			return ""
+		}
+		t := reflect.TypeOf(msg).Elem()
+		imports[t.PkgPath()] = struct{}{}
+		return path.Base(t.PkgPath()) + "." + t.Name()
+	}
+	var stubs []stub
+	for _, s := range registry.All() {
+		// 只有推送的命令由服务端发起，客户端不能调用
+		if s.Request == nil && s.Response == nil && s.Push != nil {
+			continue
+		}
+		stubs = append(stubs, stub{
+			Command:  s.Command,
+			Method:   methodName(s.Command),
+			Request:  goType(s.Request),
+			Response: goType(s.Response),
+			Push:     goType(s.Push),
+		})
+	}
+	list := make([]string, 0, len(imports))
+	for imp := range imports {
+		list = append(list, imp)
+	}
+	sort.Strings(list)
+
+	var buf bytes.Buffer
+	err := stubTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"Imports": list,
+		"Stubs":   stubs,
+	})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format generated stubs: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// methodName chat.user.talk -> ChatUserTalk
+func methodName(command string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(command, ".") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
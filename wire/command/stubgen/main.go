@@ -0,0 +1,25 @@
+// stubgen 根据wire/command中登记的命令生成客户端桩代码
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"cirno-im/wire/command"
+)
+
+func main() {
+	out := flag.String("o", "client_gen.go", "output file")
+	pkg := flag.String("pkg", "client", "package name of the output file")
+	flag.Parse()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err = command.GenerateStubs(f, command.Default, *pkg); err != nil {
+		log.Fatal(err)
+	}
+}
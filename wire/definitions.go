@@ -12,6 +12,8 @@ const (
 	// login
 	CommandLoginSignIn  = "login.signin"
 	CommandLoginSignOut = "login.signout"
+	// CommandLoginKickOut 连接被踢下线时的推送，账号在别处登录或者token被撤销
+	CommandLoginKickOut = "login.kickout"
 
 	// chat
 	CommandChatUserTalk  = "chat.user.talk"
@@ -28,12 +30,21 @@ const (
 	CommandGroupQuit    = "chat.group.quit"
 	CommandGroupMembers = "chat.group.members"
 	CommandGroupDetail  = "chat.group.detail"
+
+	// end-to-end encryption keys
+	CommandKeyUpload = "chat.key.upload"
+	CommandKeyFetch  = "chat.key.fetch"
+
+	// gateway 由网关直接处理，不转发给逻辑服务
+	CommandGatewayResend = "gateway.resend"
 )
 
 // Meta key of a packet
 const (
 	MetaDestServer   = "dest.server"
 	MetaDestChannels = "dest.channels"
+	// MetaCallFrom 服务间调用时发起方的ServiceID，响应会直接返回给发起方，并带上它与推送给用户的响应区分
+	MetaCallFrom = "call.from"
 )
 
 type Protocol string
@@ -68,6 +79,8 @@ const (
 	OfflineSyncIndexCount     = 2000                //单次同步消息索引的数量
 	OfflineMessageExpiresIn   = 15                  // 离线消息过期时间
 	MessageMaxCountPerPage    = 200
+	KeyMaxCountPerFetch       = 100                 // 单次获取公钥的最大账号数
+	TokenRevokeExpiresIn      = time.Hour * 24 * 30 // 撤销记录的保留时间，应不小于token的有效期
 )
 
 const (
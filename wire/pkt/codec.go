@@ -0,0 +1,191 @@
+package pkt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"cirno-im/wire"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultCompressThreshold body超过这个大小才压缩，小包压缩后反而可能变大
+const DefaultCompressThreshold = 1024
+
+// Compressions 网关支持的压缩算法，按优先级排列
+var Compressions = []Compression{Compression_Zstd, Compression_Gzip}
+
+var (
+	ErrNoKey            = errors.New("body is encrypted but no key is set")
+	ErrUnknownAlgorithm = errors.New("unknown compression algorithm")
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// Codec 登录时协商出的body变换：先压缩再加密，读取时反过来
+type Codec struct {
+	Compression Compression
+	// Threshold 压缩的最小长度，为0时使用DefaultCompressThreshold
+	Threshold int
+	aead      cipher.AEAD
+}
+
+// NewCodec key为空时不加密，否则使用AES-256-GCM
+func NewCodec(compression Compression, key []byte) (*Codec, error) {
+	c := &Codec{Compression: compression}
+	if len(key) == 0 {
+		return c, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	c.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Encrypted 是否会加密body
+func (c *Codec) Encrypted() bool {
+	return c != nil && c.aead != nil
+}
+
+func (c *Codec) threshold() int {
+	if c.Threshold > 0 {
+		return c.Threshold
+	}
+	return DefaultCompressThreshold
+}
+
+// encode 变换p的Body并设置Header中的标记，已经变换过的不会重复处理
+func (c *Codec) encode(p *LogicPkt) error {
+	if p.Compression != Compression_NoCompression || p.Encrypted {
+		return nil
+	}
+	if c.Compression != Compression_NoCompression && len(p.Body) >= c.threshold() {
+		body, err := compress(c.Compression, p.Body)
+		if err != nil {
+			return err
+		}
+		p.Body, p.Compression = body, c.Compression
+	}
+	// 登录响应中带有网关的公钥，客户端此时还不能解密
+	if c.aead != nil && p.Command != wire.CommandLoginSignIn {
+		nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(p.Body)+c.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		p.Body, p.Encrypted = c.aead.Seal(nonce, nonce, p.Body, nil), true
+	}
+	return nil
+}
+
+// decode 还原p的Body并清除Header中的标记，压缩不需要Codec
+func (c *Codec) decode(p *LogicPkt) error {
+	if p.Encrypted {
+		if c == nil || c.aead == nil {
+			return ErrNoKey
+		}
+		size := c.aead.NonceSize()
+		if len(p.Body) < size {
+			return fmt.Errorf("encrypted body is too short: %d", len(p.Body))
+		}
+		body, err := c.aead.Open(nil, p.Body[:size], p.Body[size:], nil)
+		if err != nil {
+			return err
+		}
+		p.Body, p.Encrypted = body, false
+	}
+	if p.Compression != Compression_NoCompression {
+		body, err := decompress(p.Compression, p.Body)
+		if err != nil {
+			return err
+		}
+		p.Body, p.Compression = body, Compression_NoCompression
+	}
+	return nil
+}
+
+func compress(algorithm Compression, data []byte) ([]byte, error) {
+	switch algorithm {
+	case Compression_Zstd:
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data))), nil
+	case Compression_Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, ErrUnknownAlgorithm
+}
+
+func decompress(algorithm Compression, data []byte) ([]byte, error) {
+	switch algorithm {
+	case Compression_Zstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	case Compression_Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, ErrUnknownAlgorithm
+}
+
+// Negotiate 按照网关的优先级选出客户端也支持的压缩算法
+func Negotiate(supported []Compression) Compression {
+	for _, c := range Compressions {
+		for _, s := range supported {
+			if c == s {
+				return c
+			}
+		}
+	}
+	return Compression_NoCompression
+}
+
+// GenerateKey 生成一对X25519密钥，公钥在登录时发给对方
+func GenerateKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// SharedKey 根据己方私钥和对方公钥算出AES-256的密钥
+func SharedKey(private *ecdh.PrivateKey, peer []byte) ([]byte, error) {
+	pub, err := ecdh.X25519().NewPublicKey(peer)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := private.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256(secret)
+	return key[:], nil
+}
+
+// MarshalWith 使用codec序列化p而不修改p，同一个消息推送给协商结果不同的连接时使用
+func MarshalWith(p *LogicPkt, codec *Codec) []byte {
+	cp := &LogicPkt{Body: p.Body, codec: codec}
+	proto.Merge(&cp.Header, &p.Header)
+	return Marshal(cp)
+}
@@ -0,0 +1,63 @@
+package pkt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecCompression(t *testing.T) {
+	for _, compression := range []Compression{Compression_Gzip, Compression_Zstd} {
+		codec, err := NewCodec(compression, nil)
+		assert.Nil(t, err)
+
+		small := New("chat.talk").SetCodec(codec).WriteBody(&MessageRequest{Body: "hello"})
+		assert.Equal(t, Compression_NoCompression, small.Compression)
+
+		text := strings.Repeat("hello world ", 200)
+		large := New("chat.talk").SetCodec(codec).WriteBody(&MessageRequest{Body: text})
+		assert.Equal(t, compression, large.Compression)
+		assert.Less(t, len(large.Body), len(text))
+
+		// 压缩不需要协商结果也能读取
+		got, err := MustReadLogicPkt(bytes.NewBuffer(Marshal(large)))
+		assert.Nil(t, err)
+		var req MessageRequest
+		assert.Nil(t, got.ReadBody(&req))
+		assert.Equal(t, text, req.Body)
+	}
+}
+
+func TestCodecEncryption(t *testing.T) {
+	client, err := GenerateKey()
+	assert.Nil(t, err)
+	gateway, err := GenerateKey()
+	assert.Nil(t, err)
+	clientKey, err := SharedKey(client, gateway.PublicKey().Bytes())
+	assert.Nil(t, err)
+	gatewayKey, err := SharedKey(gateway, client.PublicKey().Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, clientKey, gatewayKey)
+
+	clientCodec, _ := NewCodec(Negotiate([]Compression{Compression_Gzip}), clientKey)
+	gatewayCodec, _ := NewCodec(Compression_Gzip, gatewayKey)
+
+	text := strings.Repeat("secret ", 200)
+	packet := New("chat.talk").WriteBody(&MessageRequest{Body: text})
+	payload := MarshalWith(packet, gatewayCodec)
+	assert.Equal(t, Compression_NoCompression, packet.Compression)
+	assert.False(t, packet.Encrypted)
+
+	got, err := MustReadLogicPkt(bytes.NewBuffer(payload))
+	assert.Nil(t, err)
+	assert.True(t, got.Encrypted)
+	assert.Equal(t, Compression_Gzip, got.Compression)
+	var req MessageRequest
+	assert.Equal(t, ErrNoKey, got.ReadBody(&req))
+
+	assert.Nil(t, got.SetCodec(clientCodec).ReadBody(&req))
+	assert.Equal(t, text, req.Body)
+	assert.False(t, got.Encrypted)
+}
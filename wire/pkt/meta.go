@@ -0,0 +1,228 @@
+package pkt
+
+import (
+	"strconv"
+)
+
+// metaEntry 一个Meta和它预先解析好的值
+type metaEntry struct {
+	*Meta
+	i int
+	f float64
+	b bool
+}
+
+func newMetaEntry(m *Meta) *metaEntry {
+	e := &metaEntry{Meta: m}
+	switch m.Type {
+	case MetaType_int:
+		e.i, _ = strconv.Atoi(m.Value)
+		e.f = float64(e.i)
+	case MetaType_float:
+		e.f, _ = strconv.ParseFloat(m.Value, 64)
+		e.i = int(e.f)
+	case MetaType_bool:
+		e.b, _ = strconv.ParseBool(m.Value)
+	}
+	return e
+}
+
+// value 按照类型返回值，与之前GetMeta的返回保持一致
+func (e *metaEntry) value() interface{} {
+	switch e.Type {
+	case MetaType_int:
+		return e.i
+	case MetaType_float:
+		return e.f
+	case MetaType_bool:
+		return e.b
+	case MetaType_bytes:
+		return e.Bytes
+	}
+	return e.Value
+}
+
+// metaIndex 按key索引的Meta，同一个key出现多次时使用最后一个，后添加的值覆盖之前的值
+type metaIndex struct {
+	src     []*Meta
+	entries map[string]*metaEntry
+}
+
+func buildMetaIndex(meta []*Meta) metaIndex {
+	idx := metaIndex{src: meta, entries: make(map[string]*metaEntry, len(meta))}
+	for _, m := range meta {
+		idx.entries[m.Key] = newMetaEntry(m)
+	}
+	return idx
+}
+
+// valid Meta在索引建立之后没有被直接修改过
+func (idx *metaIndex) valid(meta []*Meta) bool {
+	if idx.entries == nil || len(idx.src) != len(meta) {
+		return false
+	}
+	return len(meta) == 0 || &idx.src[0] == &meta[0]
+}
+
+func (p *LogicPkt) indexMeta() {
+	p.metas = buildMetaIndex(p.Meta)
+}
+
+// findMeta 索引失效时(如直接给Meta赋值)退化为线性查找，不在读取时重建索引，以免并发读取时产生竞争
+func (p *LogicPkt) findMeta(key string) (*metaEntry, bool) {
+	if p.metas.valid(p.Meta) {
+		e, ok := p.metas.entries[key]
+		return e, ok
+	}
+	if m := lastMeta(p.Meta, key); m != nil {
+		return newMetaEntry(m), true
+	}
+	return nil, false
+}
+
+func (p *LogicPkt) AddMeta(m ...*Meta) {
+	p.Meta = append(p.Meta, m...)
+	p.indexMeta()
+}
+
+func (p *LogicPkt) AddStringMeta(key, value string) {
+	p.AddMeta(&Meta{
+		Key:   key,
+		Value: value,
+		Type:  MetaType_string,
+	})
+}
+
+func (p *LogicPkt) AddIntMeta(key string, value int) {
+	p.AddMeta(&Meta{
+		Key:   key,
+		Value: strconv.Itoa(value),
+		Type:  MetaType_int,
+	})
+}
+
+func (p *LogicPkt) AddBoolMeta(key string, value bool) {
+	p.AddMeta(&Meta{
+		Key:   key,
+		Value: strconv.FormatBool(value),
+		Type:  MetaType_bool,
+	})
+}
+
+// AddBytesMeta 二进制的值，不认识bytes类型的旧版本读到的是空字符串
+func (p *LogicPkt) AddBytesMeta(key string, value []byte) {
+	p.AddMeta(&Meta{
+		Key:   key,
+		Bytes: value,
+		Type:  MetaType_bytes,
+	})
+}
+
+// GetMeta extra value
+func (p *LogicPkt) GetMeta(key string) (interface{}, bool) {
+	e, ok := p.findMeta(key)
+	if !ok {
+		return nil, false
+	}
+	return e.value(), true
+}
+
+// GetString 返回字符串形式的值，bytes类型直接转换
+func (p *LogicPkt) GetString(key string) (string, bool) {
+	e, ok := p.findMeta(key)
+	if !ok {
+		return "", false
+	}
+	if e.Type == MetaType_bytes {
+		return string(e.Bytes), true
+	}
+	return e.Value, true
+}
+
+// GetInt 返回整数值，string类型的值能够解析时也会返回
+func (p *LogicPkt) GetInt(key string) (int, bool) {
+	e, ok := p.findMeta(key)
+	if !ok {
+		return 0, false
+	}
+	switch e.Type {
+	case MetaType_int, MetaType_float:
+		return e.i, true
+	case MetaType_string:
+		v, err := strconv.Atoi(e.Value)
+		return v, err == nil
+	}
+	return 0, false
+}
+
+// GetBool 返回布尔值，string类型的值能够解析时也会返回
+func (p *LogicPkt) GetBool(key string) (bool, bool) {
+	e, ok := p.findMeta(key)
+	if !ok {
+		return false, false
+	}
+	switch e.Type {
+	case MetaType_bool:
+		return e.b, true
+	case MetaType_int:
+		return e.i != 0, true
+	case MetaType_string:
+		v, err := strconv.ParseBool(e.Value)
+		return v, err == nil
+	}
+	return false, false
+}
+
+// GetBytes 返回二进制值，其它类型返回字符串形式的字节
+func (p *LogicPkt) GetBytes(key string) ([]byte, bool) {
+	e, ok := p.findMeta(key)
+	if !ok {
+		return nil, false
+	}
+	if e.Type == MetaType_bytes {
+		return e.Bytes, true
+	}
+	return []byte(e.Value), true
+}
+
+// DelMeta 删除key的所有值
+func (p *LogicPkt) DelMeta(key string) {
+	meta := make([]*Meta, 0, len(p.Meta))
+	for _, m := range p.Meta {
+		if m.Key != key {
+			meta = append(meta, m)
+		}
+	}
+	p.Meta = meta
+	p.indexMeta()
+}
+
+// lastMeta 返回key的最后一个值，与索引的规则一致
+func lastMeta(meta []*Meta, key string) *Meta {
+	for i := len(meta) - 1; i >= 0; i-- {
+		if meta[i].Key == key {
+			return meta[i]
+		}
+	}
+	return nil
+}
+
+// FindMeta 在Header的Meta中查找，没有LogicPkt时使用
+func FindMeta(meta []*Meta, key string) (interface{}, bool) {
+	if m := lastMeta(meta, key); m != nil {
+		return newMetaEntry(m).value(), true
+	}
+	return nil, false
+}
+
+// FindString 在Header的Meta中查找字符串形式的值
+func FindString(meta []*Meta, key string) (string, bool) {
+	m := lastMeta(meta, key)
+	if m == nil {
+		return "", false
+	}
+	if m.Type == MetaType_bytes {
+		return string(m.Bytes), true
+	}
+	return m.Value, true
+}
@@ -0,0 +1,66 @@
+package pkt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedMeta(t *testing.T) {
+	packet := New("chat.user.talk")
+	packet.AddStringMeta("s", "hello")
+	packet.AddIntMeta("i", 42)
+	packet.AddBoolMeta("b", true)
+	packet.AddBytesMeta("raw", []byte{0x00, 0xff})
+	// 同一个key后添加的值生效，客户端先带上的值不能覆盖服务端添加的值
+	packet.AddStringMeta("s", "world")
+
+	got, err := MustReadLogicPkt(bytes.NewBuffer(Marshal(packet)))
+	assert.Nil(t, err)
+
+	s, ok := got.GetString("s")
+	assert.True(t, ok)
+	assert.Equal(t, "world", s)
+	s, _ = FindString(got.Meta, "s")
+	assert.Equal(t, "world", s)
+	i, ok := got.GetInt("i")
+	assert.True(t, ok)
+	assert.Equal(t, 42, i)
+	b, ok := got.GetBool("b")
+	assert.True(t, ok)
+	assert.True(t, b)
+	raw, ok := got.GetBytes("raw")
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0x00, 0xff}, raw)
+	v, ok := got.GetMeta("i")
+	assert.True(t, ok)
+	assert.Equal(t, 42, v)
+
+	_, ok = got.GetInt("s")
+	assert.False(t, ok)
+	_, ok = got.GetString("none")
+	assert.False(t, ok)
+
+	got.DelMeta("s")
+	_, ok = got.GetString("s")
+	assert.False(t, ok)
+	assert.Equal(t, 3, len(got.Meta))
+}
+
+func TestMetaDirectAssign(t *testing.T) {
+	packet := New("chat.user.talk")
+	packet.AddStringMeta("a", "1")
+
+	// 直接修改Meta后索引失效，需要仍然能读到新的值
+	packet.Meta = []*Meta{{Key: "b", Value: "1", Type: MetaType_int}, {Key: "b", Value: "2", Type: MetaType_int}}
+	_, ok := packet.GetString("a")
+	assert.False(t, ok)
+	i, ok := packet.GetInt("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, i)
+
+	s, ok := FindString(packet.Meta, "b")
+	assert.True(t, ok)
+	assert.Equal(t, "2", s)
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"google.golang.org/protobuf/proto"
 	"io"
-	"strconv"
 	"strings"
 )
 
@@ -15,6 +14,10 @@ import (
 type LogicPkt struct {
 	Header
 	Body []byte
+	// metas Meta的索引，只在修改Meta时重建，读取时不会写入
+	metas metaIndex
+	// codec 登录时协商出的body压缩和加密方式，为空时Body不做变换
+	codec *Codec
 }
 
 type HeaderOption func(header *Header)
@@ -75,6 +78,7 @@ func (p *LogicPkt) Decode(r io.Reader) error {
 	if err := proto.Unmarshal(headerBytes, &p.Header); err != nil {
 		return err
 	}
+	p.indexMeta()
 	p.Body, err = endian.ReadBytes(r)
 	if err != nil {
 		return err
@@ -83,6 +87,11 @@ func (p *LogicPkt) Decode(r io.Reader) error {
 }
 
 func (p *LogicPkt) Encode(w io.Writer) error {
+	if p.codec != nil {
+		if err := p.codec.encode(p); err != nil {
+			return err
+		}
+	}
 	headerBytes, err := proto.Marshal(&p.Header)
 	if err != nil {
 		return err
@@ -96,7 +105,31 @@ func (p *LogicPkt) Encode(w io.Writer) error {
 	return nil
 }
 
+// SetCodec 设置body的变换方式，之后的WriteBody、ReadBody和Encode都会使用它
+func (p *LogicPkt) SetCodec(codec *Codec) *LogicPkt {
+	p.codec = codec
+	return p
+}
+
+// RestoreBody 把压缩或者加密过的Body还原成原始内容
+func (p *LogicPkt) RestoreBody() error {
+	return p.codec.decode(p)
+}
+
+// PlainBody 返回还原后的Body而不修改p
+func (p *LogicPkt) PlainBody() ([]byte, error) {
+	cp := &LogicPkt{Body: p.Body, codec: p.codec}
+	cp.Compression, cp.Encrypted = p.Compression, p.Encrypted
+	if err := cp.RestoreBody(); err != nil {
+		return nil, err
+	}
+	return cp.Body, nil
+}
+
 func (p *LogicPkt) ReadBody(val proto.Message) error {
+	if err := p.RestoreBody(); err != nil {
+		return err
+	}
 	return json.Unmarshal(p.Body, val)
 }
 
@@ -105,6 +138,10 @@ func (p *LogicPkt) WriteBody(val proto.Message) *LogicPkt {
 		return p
 	}
 	p.Body, _ = json.Marshal(val)
+	p.Compression, p.Encrypted = Compression_NoCompression, false
+	if p.codec != nil {
+		_ = p.codec.encode(p)
+	}
 	return p
 }
 
@@ -123,49 +160,3 @@ func (p *LogicPkt) ServiceName() string {
 	}
 	return arr[0]
 }
-
-func (p *LogicPkt) AddMeta(m ...*Meta) {
-	p.Meta = append(p.Meta, m...)
-}
-
-func (p *LogicPkt) AddStringMeta(key, value string) {
-	p.AddMeta(&Meta{
-		Key:   key,
-		Value: value,
-		Type:  MetaType_string,
-	})
-}
-
-// GetMeta extra value
-func (p *LogicPkt) GetMeta(key string) (interface{}, bool) {
-	return FindMeta(p.Meta, key)
-}
-
-func FindMeta(meta []*Meta, key string) (interface{}, bool) {
-	for _, m := range meta {
-		if m.Key == key {
-			switch m.Type {
-			case MetaType_int:
-				v, _ := strconv.Atoi(m.Value)
-				return v, true
-			case MetaType_float:
-				v, _ := strconv.ParseFloat(m.Value, 64)
-				return v, true
-			}
-			return m.Value, true
-		}
-	}
-	return nil, false
-}
-
-func (p *LogicPkt) DelMeta(key string) {
-	for i, m := range p.Meta {
-		if m.Key == key {
-			length := len(p.Meta)
-			if i < length-1 {
-				copy(p.Meta[i:], p.Meta[i+1:])
-			}
-			p.Meta = p.Meta[:length-1]
-		}
-	}
-}
@@ -30,12 +30,8 @@ func TestReadPkt(t *testing.T) {
 		Key:   wire.MetaDestChannels,
 		Value: "test1,test2",
 	})
-	buf := new(bytes.Buffer)
-	_ = packet.Encode(buf)
-
+	buf := bytes.NewBuffer(Marshal(packet))
 	t.Log(buf.Bytes())
-	// r := bytes.NewBuffer(Marshal(packet))
-	//
 
 	got, err := Read(buf)
 	p := got.(*LogicPkt)
@@ -0,0 +1,57 @@
+package pkt
+
+// ReplayWindowSize 去重窗口的大小，比窗口中最大序号小这么多的包都视为重复
+const ReplayWindowSize = 64
+
+// ReplayWindow 记录一个channel最近收到的请求序号，用于识别客户端重试发送的包，不是并发安全的
+type ReplayWindow struct {
+	max    uint32
+	bitmap uint64
+}
+
+// Accept 第一次收到seq时返回true，重复或者已经落在窗口之外时返回false，seq为0时不检查
+func (w *ReplayWindow) Accept(seq uint32) bool {
+	if seq == 0 {
+		return true
+	}
+	if seq > w.max {
+		shift := seq - w.max
+		if shift >= ReplayWindowSize {
+			w.bitmap = 1
+		} else {
+			w.bitmap = w.bitmap<<shift | 1
+		}
+		w.max = seq
+		return true
+	}
+	diff := w.max - seq
+	if diff >= ReplayWindowSize {
+		return false
+	}
+	bit := uint64(1) << diff
+	if w.bitmap&bit != 0 {
+		return false
+	}
+	w.bitmap |= bit
+	return true
+}
+
+// PushTracker 客户端记录收到的PushSeq，发现缺口时返回需要请求重发的范围，不是并发安全的
+type PushTracker struct {
+	last uint32
+}
+
+// Track 收到pushSeq为seq的包，返回缺失的[from, to]，ok为false表示没有缺口
+func (t *PushTracker) Track(seq uint32) (from, to uint32, ok bool) {
+	if seq <= t.last {
+		return 0, 0, false
+	}
+	from, to = t.last+1, seq-1
+	t.last = seq
+	return from, to, from <= to
+}
+
+// Last 最近收到的最大的pushSeq
+func (t *PushTracker) Last() uint32 {
+	return t.last
+}
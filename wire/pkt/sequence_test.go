@@ -0,0 +1,41 @@
+package pkt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayWindow(t *testing.T) {
+	var w ReplayWindow
+	assert.True(t, w.Accept(1))
+	assert.True(t, w.Accept(3))
+	assert.False(t, w.Accept(1))
+	assert.False(t, w.Accept(3))
+	// 乱序到达的包不是重复
+	assert.True(t, w.Accept(2))
+	assert.True(t, w.Accept(0))
+	assert.True(t, w.Accept(0))
+
+	assert.True(t, w.Accept(3+ReplayWindowSize))
+	// 已经落在窗口之外
+	assert.False(t, w.Accept(3))
+	assert.True(t, w.Accept(4))
+}
+
+func TestPushTracker(t *testing.T) {
+	var tracker PushTracker
+	_, _, ok := tracker.Track(1)
+	assert.False(t, ok)
+	_, _, ok = tracker.Track(2)
+	assert.False(t, ok)
+
+	from, to, ok := tracker.Track(5)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(3), from)
+	assert.Equal(t, uint32(4), to)
+	// 重发的包不会改变记录
+	_, _, ok = tracker.Track(3)
+	assert.False(t, ok)
+	assert.Equal(t, uint32(5), tracker.Last())
+}
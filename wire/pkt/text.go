@@ -0,0 +1,95 @@
+package pkt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Envelope 浏览器客户端在OpText帧中使用的JSON格式，body与LogicPkt.Body相同，是一个JSON对象
+type Envelope struct {
+	Command   string            `json:"command"`
+	ChannelID string            `json:"channelId,omitempty"`
+	Sequence  uint32            `json:"sequence,omitempty"`
+	PushSeq   uint32            `json:"pushSeq,omitempty"`
+	Flag      string            `json:"flag,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Dest      string            `json:"dest,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	Body      json.RawMessage   `json:"body,omitempty"`
+}
+
+// MarshalText 把LogicPkt转换为JSON格式，压缩或加密过的Body会先还原
+func MarshalText(p *LogicPkt) ([]byte, error) {
+	body, err := p.PlainBody()
+	if err != nil {
+		return nil, err
+	}
+	env := Envelope{
+		Command:   p.Command,
+		ChannelID: p.ChannelID,
+		Sequence:  p.Sequence,
+		PushSeq:   p.PushSeq,
+		Flag:      p.Flag.String(),
+		Status:    p.Status.String(),
+		Dest:      p.Dest,
+	}
+	if len(body) > 0 {
+		env.Body = body
+	}
+	if len(p.Meta) > 0 {
+		env.Meta = make(map[string]string, len(p.Meta))
+		for _, m := range p.Meta {
+			if _, ok := env.Meta[m.Key]; ok {
+				continue
+			}
+			env.Meta[m.Key], _ = FindString(p.Meta, m.Key)
+		}
+	}
+	return json.Marshal(&env)
+}
+
+// ReadText 从JSON格式中读取LogicPkt，Meta都作为string类型
+func ReadText(data []byte) (*LogicPkt, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	if env.Command == "" {
+		return nil, errors.New("command is empty")
+	}
+	p := &LogicPkt{}
+	p.Command = env.Command
+	p.ChannelID = env.ChannelID
+	p.Sequence = env.Sequence
+	p.PushSeq = env.PushSeq
+	p.Dest = env.Dest
+	if env.Flag != "" {
+		flag, ok := Flag_value[env.Flag]
+		if !ok {
+			return nil, fmt.Errorf("unknown flag %s", env.Flag)
+		}
+		p.Flag = Flag(flag)
+	}
+	if env.Status != "" {
+		status, ok := Status_value[env.Status]
+		if !ok {
+			return nil, fmt.Errorf("unknown status %s", env.Status)
+		}
+		p.Status = Status(status)
+	}
+	keys := make([]string, 0, len(env.Meta))
+	for k := range env.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p.Meta = append(p.Meta, &Meta{Key: k, Value: env.Meta[k], Type: MetaType_string})
+	}
+	p.indexMeta()
+	if len(env.Body) > 0 && string(env.Body) != "null" {
+		p.Body = env.Body
+	}
+	return p, nil
+}
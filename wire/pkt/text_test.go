@@ -0,0 +1,52 @@
+package pkt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadText(t *testing.T) {
+	data := `{"command":"chat.user.talk","sequence":3,"dest":"test2","meta":{"app":"cim"},"body":{"type":1,"body":"hello"}}`
+	p, err := ReadText([]byte(data))
+	assert.Nil(t, err)
+	assert.Equal(t, "chat.user.talk", p.Command)
+	assert.Equal(t, uint32(3), p.Sequence)
+	assert.Equal(t, Flag_Request, p.Flag)
+	app, _ := p.GetString("app")
+	assert.Equal(t, "cim", app)
+
+	var req MessageRequest
+	assert.Nil(t, p.ReadBody(&req))
+	assert.Equal(t, "hello", req.Body)
+
+	_, err = ReadText([]byte(`{"command":"chat.user.talk","flag":"Unknown"}`))
+	assert.NotNil(t, err)
+	_, err = ReadText([]byte(`{"sequence":1}`))
+	assert.NotNil(t, err)
+}
+
+func TestMarshalText(t *testing.T) {
+	codec, err := NewCodec(Compression_Gzip, nil)
+	assert.Nil(t, err)
+	text := strings.Repeat("hello world ", 200)
+	p := New("chat.user.talk", WithSequence(5)).SetCodec(codec).WriteBody(&MessagePush{Sender: "test1", Body: text})
+	p.Flag = Flag_Push
+	p.AddStringMeta("app", "cim")
+	assert.Equal(t, Compression_Gzip, p.Compression)
+
+	// 压缩过的body转为JSON时会被还原
+	data, err := MarshalText(p)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"flag":"Push"`)
+
+	got, err := ReadText(data)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(5), got.Sequence)
+	assert.Equal(t, Flag_Push, got.Flag)
+	var push MessagePush
+	assert.Nil(t, got.ReadBody(&push))
+	assert.Equal(t, "test1", push.Sender)
+	assert.Equal(t, text, push.Body)
+}
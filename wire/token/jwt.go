@@ -2,6 +2,7 @@ package token
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	jwtgo "github.com/dgrijalva/jwt-go"
@@ -17,15 +18,27 @@ type Token struct {
 	Account string `json:"acc,omitempty"`
 	App     string `json:"app,omitempty"`
 	Exp     int64  `json:"exp,omitempty"`
+	Nbf     int64  `json:"nbf,omitempty"`
+	Aud     string `json:"aud,omitempty"`
+	// ID 撤销单个token时使用，Iat 撤销账号时早于撤销时间签发的token都失效
+	ID  string `json:"jti,omitempty"`
+	Iat int64  `json:"iat,omitempty"`
 }
 
-var errExpiredToken = errors.New("expired token")
+var (
+	errExpiredToken  = errors.New("expired token")
+	errTokenNotValid = errors.New("token is not valid yet")
+)
 
 // Valid Valid
 func (t *Token) Valid() error {
-	if t.Exp < time.Now().Unix() {
+	now := time.Now().Unix()
+	if t.Exp < now {
 		return errExpiredToken
 	}
+	if t.Nbf > now {
+		return errTokenNotValid
+	}
 	return nil
 }
 
@@ -46,3 +59,16 @@ func Generate(secret string, token *Token) (string, error) {
 	jtk := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, token)
 	return jtk.SignedString([]byte(secret))
 }
+
+// Sign 使用alg对应的私钥签发token，kid写入header中；HS256的key为[]byte，RS256为*rsa.PrivateKey，EdDSA为ed25519.PrivateKey
+func Sign(alg, kid string, key interface{}, token *Token) (string, error) {
+	method := jwtgo.GetSigningMethod(alg)
+	if method == nil {
+		return "", fmt.Errorf("unsupported alg %s", alg)
+	}
+	jtk := jwtgo.NewWithClaims(method, token)
+	if kid != "" {
+		jtk.Header["kid"] = kid
+	}
+	return jtk.SignedString(key)
+}
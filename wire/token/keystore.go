@@ -0,0 +1,180 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+// 支持的签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// AnyApp 对所有app生效的密钥，app没有自己的密钥时使用
+const AnyApp = "*"
+
+var (
+	ErrKeyNotFound     = errors.New("token key not found")
+	ErrInvalidAudience = errors.New("invalid audience")
+)
+
+// Key 验证token使用的密钥，HS256使用Secret，RS256和EdDSA使用PEM格式的PublicKey
+type Key struct {
+	App       string `json:"app"`
+	ID        string `json:"kid"`
+	Alg       string `json:"alg"`
+	Secret    string `json:"secret,omitempty"`
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+type verifyKey struct {
+	alg string
+	key interface{}
+	// retiredAt 不为零表示已经被轮换掉，宽限期过后不再使用
+	retiredAt time.Time
+}
+
+// KeyStore 按app和kid索引的密钥，用于验证登录时的token
+type KeyStore struct {
+	sync.RWMutex
+	keys     map[string]*verifyKey
+	audience string
+	grace    time.Duration
+}
+
+// NewKeyStore audience不为空时要求token的aud与之相同，grace为轮换后旧密钥的宽限期
+func NewKeyStore(audience string, grace time.Duration) *KeyStore {
+	return &KeyStore{
+		keys:     make(map[string]*verifyKey),
+		audience: audience,
+		grace:    grace,
+	}
+}
+
+func keyIndex(app, kid string) string {
+	return app + "/" + kid
+}
+
+// Update 用keys替换当前的密钥，不在keys中的旧密钥在宽限期内仍然有效；有密钥无法解析时不做任何修改
+func (s *KeyStore) Update(keys []Key) error {
+	parsed := make(map[string]*verifyKey, len(keys))
+	for _, k := range keys {
+		vk, err := parseKey(k)
+		if err != nil {
+			return fmt.Errorf("key %s of app %s: %w", k.ID, k.App, err)
+		}
+		parsed[keyIndex(k.App, k.ID)] = vk
+	}
+	now := time.Now()
+	s.Lock()
+	defer s.Unlock()
+	for index, old := range s.keys {
+		if _, ok := parsed[index]; ok {
+			continue
+		}
+		if old.retiredAt.IsZero() {
+			old.retiredAt = now
+		}
+		if now.Sub(old.retiredAt) < s.grace {
+			parsed[index] = old
+		}
+	}
+	s.keys = parsed
+	return nil
+}
+
+// Parse 验证token的签名、有效期和aud，按token中的app和header中的kid查找密钥
+func (s *KeyStore) Parse(tk string) (*Token, error) {
+	var token = new(Token)
+	_, err := jwtgo.ParseWithClaims(tk, token, func(jwttk *jwtgo.Token) (interface{}, error) {
+		kid, _ := jwttk.Header["kid"].(string)
+		vk, err := s.lookup(token.App, kid)
+		if err != nil {
+			return nil, err
+		}
+		// 防止用公钥作为HS256的secret伪造token
+		if jwttk.Method.Alg() != vk.alg {
+			return nil, fmt.Errorf("unexpected signing method %s", jwttk.Method.Alg())
+		}
+		return vk.key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.audience != "" && token.Aud != s.audience {
+		return nil, ErrInvalidAudience
+	}
+	return token, nil
+}
+
+func (s *KeyStore) lookup(app, kid string) (*verifyKey, error) {
+	s.RLock()
+	defer s.RUnlock()
+	for _, index := range []string{keyIndex(app, kid), keyIndex(AnyApp, kid)} {
+		vk, ok := s.keys[index]
+		if !ok {
+			continue
+		}
+		if !vk.retiredAt.IsZero() && time.Since(vk.retiredAt) >= s.grace {
+			continue
+		}
+		return vk, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func parseKey(k Key) (*verifyKey, error) {
+	switch k.Alg {
+	case AlgHS256:
+		if k.Secret == "" {
+			return nil, errors.New("secret is empty")
+		}
+		return &verifyKey{alg: k.Alg, key: []byte(k.Secret)}, nil
+	case AlgRS256:
+		key, err := jwtgo.ParseRSAPublicKeyFromPEM([]byte(k.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		return &verifyKey{alg: k.Alg, key: key}, nil
+	case AlgEdDSA:
+		block, _ := pem.Decode([]byte(k.PublicKey))
+		if block == nil {
+			return nil, errors.New("public key is not PEM encoded")
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not ed25519")
+		}
+		return &verifyKey{alg: k.Alg, key: publicKey}, nil
+	default:
+		return nil, fmt.Errorf("unsupported alg %s", k.Alg)
+	}
+}
+
+// LoadKeys 从JSON文件中读取密钥列表
+func LoadKeys(file string) ([]Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var keys []Key
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
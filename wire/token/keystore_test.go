@@ -0,0 +1,102 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func publicPEM(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.Nil(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func newToken(app string) *Token {
+	return &Token{Account: "test1", App: app, Aud: "cim", Exp: time.Now().Add(time.Hour).Unix()}
+}
+
+func TestKeyStore(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	store := NewKeyStore("cim", time.Hour)
+	err = store.Update([]Key{
+		{App: AnyApp, Alg: AlgHS256, Secret: "default"},
+		{App: "app1", ID: "k1", Alg: AlgHS256, Secret: "app1-secret"},
+		{App: "app2", ID: "k2", Alg: AlgRS256, PublicKey: publicPEM(t, &rsaKey.PublicKey)},
+		{App: "app3", ID: "k3", Alg: AlgEdDSA, PublicKey: publicPEM(t, edPublic)},
+	})
+	assert.Nil(t, err)
+
+	cases := []struct {
+		alg, kid string
+		key      interface{}
+		app      string
+	}{
+		{AlgHS256, "", []byte("default"), "app0"},
+		{AlgHS256, "k1", []byte("app1-secret"), "app1"},
+		{AlgRS256, "k2", rsaKey, "app2"},
+		{AlgEdDSA, "k3", edPrivate, "app3"},
+	}
+	for _, c := range cases {
+		tk, err := Sign(c.alg, c.kid, c.key, newToken(c.app))
+		assert.Nil(t, err)
+		got, err := store.Parse(tk)
+		assert.Nil(t, err, c.alg)
+		assert.Equal(t, c.app, got.App)
+	}
+
+	// 其它app的密钥不能使用
+	tk, _ := Sign(AlgHS256, "k1", []byte("app1-secret"), newToken("app2"))
+	_, err = store.Parse(tk)
+	assert.NotNil(t, err)
+
+	// 用RSA公钥作为HS256的secret伪造token
+	tk, _ = Sign(AlgHS256, "k2", []byte(publicPEM(t, &rsaKey.PublicKey)), newToken("app2"))
+	_, err = store.Parse(tk)
+	assert.NotNil(t, err)
+
+	aud := newToken("app1")
+	aud.Aud = "other"
+	tk, _ = Sign(AlgHS256, "k1", []byte("app1-secret"), aud)
+	_, err = store.Parse(tk)
+	assert.Equal(t, ErrInvalidAudience, err)
+
+	nbf := newToken("app1")
+	nbf.Nbf = time.Now().Add(time.Minute).Unix()
+	tk, _ = Sign(AlgHS256, "k1", []byte("app1-secret"), nbf)
+	_, err = store.Parse(tk)
+	assert.NotNil(t, err)
+
+	assert.NotNil(t, store.Update([]Key{{App: "app1", ID: "k1", Alg: "none"}}))
+}
+
+func TestKeyRotation(t *testing.T) {
+	store := NewKeyStore("", time.Millisecond*200)
+	assert.Nil(t, store.Update([]Key{{App: "app1", ID: "k1", Alg: AlgHS256, Secret: "old"}}))
+	old, _ := Sign(AlgHS256, "k1", []byte("old"), newToken("app1"))
+
+	assert.Nil(t, store.Update([]Key{{App: "app1", ID: "k2", Alg: AlgHS256, Secret: "new"}}))
+	latest, _ := Sign(AlgHS256, "k2", []byte("new"), newToken("app1"))
+	_, err := store.Parse(latest)
+	assert.Nil(t, err)
+	// 宽限期内旧密钥仍然有效
+	_, err = store.Parse(old)
+	assert.Nil(t, err)
+
+	time.Sleep(time.Millisecond * 250)
+	_, err = store.Parse(old)
+	assert.NotNil(t, err)
+	assert.Nil(t, store.Update([]Key{{App: "app1", ID: "k2", Alg: AlgHS256, Secret: "new"}}))
+	_, err = store.Parse(latest)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,40 @@
+package token
+
+import "errors"
+
+var (
+	ErrRevoked = errors.New("token has been revoked")
+	// ErrMissingIat 开启撤销时token必须带iat，否则无法判断它是否在撤销账号之后签发
+	ErrMissingIat = errors.New("token has no iat")
+)
+
+// Revocation 撤销一个token或者一个账号在At之前签发的所有token，会广播给所有网关
+type Revocation struct {
+	App     string `json:"app"`
+	Account string `json:"account,omitempty"`
+	// TokenID 不为空时只撤销App中的这个token，不同app签发的jti可能重复
+	TokenID string `json:"jti,omitempty"`
+	At      int64  `json:"at"`
+}
+
+// Revokes token是否被撤销，撤销账号时不带iat的token也视为被撤销，调用方需要先要求token带iat
+func (r *Revocation) Revokes(t *Token) bool {
+	if r.App != t.App {
+		return false
+	}
+	if r.TokenID != "" {
+		return r.TokenID == t.ID
+	}
+	return r.Account == t.Account && t.Iat <= r.At
+}
+
+// Match 已经登录的连接是否需要被踢下线，参数来自channel的meta
+func (r *Revocation) Match(app, account, tokenID string) bool {
+	if r.App != app {
+		return false
+	}
+	if r.TokenID != "" {
+		return r.TokenID == tokenID
+	}
+	return r.Account == account
+}
@@ -0,0 +1,28 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocation(t *testing.T) {
+	tk := &Token{App: "cim", Account: "test1", ID: "t1", Iat: 100}
+
+	byAccount := &Revocation{App: "cim", Account: "test1", At: 100}
+	assert.True(t, byAccount.Revokes(tk))
+	// 撤销之后签发的token仍然有效
+	assert.False(t, byAccount.Revokes(&Token{App: "cim", Account: "test1", Iat: 101}))
+	assert.False(t, byAccount.Revokes(&Token{App: "other", Account: "test1", Iat: 1}))
+	assert.True(t, byAccount.Match("cim", "test1", "t2"))
+	assert.False(t, byAccount.Match("cim", "test2", "t1"))
+
+	byID := &Revocation{App: "cim", TokenID: "t1", At: 50}
+	assert.True(t, byID.Revokes(tk))
+	assert.False(t, byID.Revokes(&Token{App: "cim", Account: "test1", ID: "t2"}))
+	assert.True(t, byID.Match("cim", "test1", "t1"))
+	assert.False(t, byID.Match("cim", "test1", "t2"))
+	// jti只在app内唯一
+	assert.False(t, byID.Revokes(&Token{App: "other", Account: "test1", ID: "t1"}))
+	assert.False(t, byID.Match("other", "test1", "t1"))
+}